@@ -0,0 +1,144 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// algorithmSpec describes a hash function usable for HMAC-based OTPs.
+type algorithmSpec struct {
+	name string
+	size int
+	hmac hashPool
+}
+
+var (
+	builtinAlgorithms = []*algorithmSpec{
+		SHA1:   newAlgorithmSpec("SHA1", sha1.New),
+		SHA256: newAlgorithmSpec("SHA256", sha256.New),
+		SHA512: newAlgorithmSpec("SHA512", sha512.New),
+	}
+
+	// registered holds the built-in algorithms followed by those added by RegisterAlgorithm,
+	// or nil if none were added. It is replaced copy-on-write, so lookups take no lock.
+	registered atomic.Pointer[[]*algorithmSpec]
+
+	// registerMu serializes RegisterAlgorithm.
+	registerMu sync.Mutex
+)
+
+// loadAlgorithms returns all algorithms indexed by Algorithm. It does not depend on
+// package initialization order, so RegisterAlgorithm can be used in var declarations.
+func loadAlgorithms() []*algorithmSpec {
+	if p := registered.Load(); p != nil {
+		return *p
+	}
+	return builtinAlgorithms
+}
+
+func newAlgorithmSpec(name string, newHash func() hash.Hash) *algorithmSpec {
+	return &algorithmSpec{
+		name: name,
+		size: newHash().Size(),
+		hmac: hashPool{
+			pool: &sync.Pool{},
+			new: func(key []byte) hash.Hash {
+				return hmac.New(newHash, key)
+			},
+		},
+	}
+}
+
+// RegisterAlgorithm makes a hash function available as an Algorithm under name, e.g.
+//
+//	var SHA512_256 = otp.MustRegisterAlgorithm("SHA512/256", sha512.New512_256)
+//
+// The returned Algorithm works everywhere the built-in ones do: HOTP/TOTP generation and
+// validation, RandomSecret and DeriveSecret (sized to the hash output), otpauth URLs
+// (`algorithm=SHA512/256`) and OCRA suites (`OCRA-1:HOTP-SHA512/256-8:QN08`).
+//
+// Names are case-insensitive and stored upper-cased; they may contain letters, digits and
+// "-", "_", "/" or ".". Registering a name twice returns ErrAlgorithmRegistered. Since
+// Algorithm values are assigned in registration order, persist algorithms by name, not by
+// value. RegisterAlgorithm is safe for concurrent use but is meant to be called from init.
+func RegisterAlgorithm(name string, newHash func() hash.Hash) (Algorithm, error) {
+	name = strings.ToUpper(name)
+	if !validAlgorithmName(name) {
+		return 0, fmt.Errorf("invalid algorithm name %q", name)
+	}
+	if newHash == nil {
+		return 0, fmt.Errorf("nil hash constructor for algorithm %q", name)
+	}
+
+	registerMu.Lock()
+	defer registerMu.Unlock()
+
+	specs := loadAlgorithms()
+	for _, spec := range specs {
+		if spec.name == name {
+			return 0, fmt.Errorf("%w: %s", ErrAlgorithmRegistered, name)
+		}
+	}
+	if len(specs) > int(^Algorithm(0)) {
+		return 0, fmt.Errorf("too many registered algorithms")
+	}
+
+	next := make([]*algorithmSpec, len(specs), len(specs)+1)
+	copy(next, specs)
+	next = append(next, newAlgorithmSpec(name, newHash))
+	registered.Store(&next)
+
+	return Algorithm(len(specs)), nil
+}
+
+// MustRegisterAlgorithm is like RegisterAlgorithm but panics on error.
+func MustRegisterAlgorithm(name string, newHash func() hash.Hash) Algorithm {
+	algo, err := RegisterAlgorithm(name, newHash)
+	if err != nil {
+		panic(err)
+	}
+	return algo
+}
+
+// ParseAlgorithm returns the registered Algorithm with the given name, case-insensitively.
+// It returns ErrUnsupportedAlgorithm for unknown names.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	name = strings.ToUpper(name)
+	for i, spec := range loadAlgorithms() {
+		if spec.name == name {
+			return Algorithm(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, name)
+}
+
+// lookupAlgorithm returns the spec of a registered algorithm.
+func lookupAlgorithm(algo Algorithm) (*algorithmSpec, error) {
+	specs := loadAlgorithms()
+	if int(algo) >= len(specs) {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	return specs[algo], nil
+}
+
+func validAlgorithmName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '/', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package otp
+
+import (
+	"crypto/sha3"
+	"crypto/sha512"
+	"encoding/base32"
+	"errors"
+	"hash"
+	"net/url"
+	"testing"
+)
+
+var (
+	testSHA3_256   = MustRegisterAlgorithm("sha3-256", func() hash.Hash { return sha3.New256() })
+	testSHA512_256 = MustRegisterAlgorithm("SHA512/256", sha512.New512_256)
+)
+
+// 32-byte key "12345678901234567890123456789012", as in RFC 6238 for SHA256.
+var registryTestSecret = base32.StdEncoding.WithPadding(base32.NoPadding).
+	EncodeToString([]byte("12345678901234567890123456789012"))
+
+func TestRegisterAlgorithm(t *testing.T) {
+	if testSHA3_256.String() != "SHA3-256" {
+		t.Errorf("String() = %q, want SHA3-256", testSHA3_256.String())
+	}
+
+	for _, name := range []string{"SHA3-256", "sha3-256", "Sha3-256"} {
+		algo, err := ParseAlgorithm(name)
+		if err != nil || algo != testSHA3_256 {
+			t.Errorf("ParseAlgorithm(%q) = %v, %v", name, algo, err)
+		}
+	}
+	if AlgorithmFromStr("SHA512/256") != testSHA512_256 {
+		t.Error("AlgorithmFromStr did not resolve a registered algorithm")
+	}
+	if AlgorithmFromStr("unknown") != SHA1 {
+		t.Error("AlgorithmFromStr must default to SHA1")
+	}
+	if _, err := ParseAlgorithm("unknown"); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+
+	if _, err := RegisterAlgorithm("SHA3-256", func() hash.Hash { return sha3.New256() }); !errors.Is(err, ErrAlgorithmRegistered) {
+		t.Errorf("duplicate: expected ErrAlgorithmRegistered, got %v", err)
+	}
+	if _, err := RegisterAlgorithm("sha1", sha512.New); !errors.Is(err, ErrAlgorithmRegistered) {
+		t.Errorf("built-in: expected ErrAlgorithmRegistered, got %v", err)
+	}
+	for _, name := range []string{"", "HOTP:SHA", "SHA 3", "SHA3&x"} {
+		if _, err := RegisterAlgorithm(name, sha512.New); err == nil {
+			t.Errorf("RegisterAlgorithm(%q) should fail", name)
+		}
+	}
+	if _, err := RegisterAlgorithm("NILHASH", nil); err == nil {
+		t.Error("RegisterAlgorithm with nil constructor should fail")
+	}
+}
+
+func TestRegisteredAlgorithm_HOTP(t *testing.T) {
+	tests := []struct {
+		algo     Algorithm
+		digits   Digits
+		expected []string
+	}{
+		{testSHA3_256, SixDigits, []string{"355535", "503818", "122744"}},
+		{testSHA512_256, EightDigits, []string{"80926481", "00441233", "59655125"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algo.String(), func(t *testing.T) {
+			param := &Param{Digits: tt.digits, Algorithm: tt.algo}
+			for counter, want := range tt.expected {
+				got, err := GenerateHOTP(registryTestSecret, uint64(counter), param)
+				if err != nil {
+					t.Fatalf("GenerateHOTP failed: %v", err)
+				}
+				if got != want {
+					t.Errorf("counter %d: got %s, want %s", counter, got, want)
+				}
+				if ok, err := ValidateHOTP(registryTestSecret, got, uint64(counter), param); !ok || err != nil {
+					t.Errorf("ValidateHOTP = %v, %v", ok, err)
+				}
+			}
+		})
+	}
+
+	if _, err := GenerateHOTP(registryTestSecret, 0, &Param{Digits: SixDigits, Algorithm: 200}); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestRegisteredAlgorithm_SecretAndURL(t *testing.T) {
+	secret, err := RandomSecret(testSHA3_256)
+	if err != nil {
+		t.Fatalf("RandomSecret failed: %v", err)
+	}
+	raw, _ := DecodeSecret(secret)
+	if len(raw) != 32 {
+		t.Errorf("secret size = %d, want 32", len(raw))
+	}
+
+	u, err := GenerateTOTPURL(URLParam{
+		Issuer:      "Example",
+		AccountName: "alice",
+		Secret:      secret,
+		Algorithm:   testSHA3_256,
+	})
+	if err != nil {
+		t.Fatalf("GenerateTOTPURL failed: %v", err)
+	}
+	if got := u.Query().Get("algorithm"); got != "SHA3-256" {
+		t.Errorf("algorithm = %q", got)
+	}
+
+	parsed, err := ParseOTPAuthURL(u)
+	if err != nil {
+		t.Fatalf("ParseOTPAuthURL failed: %v", err)
+	}
+	if parsed.Algorithm != testSHA3_256 {
+		t.Errorf("parsed algorithm = %v", parsed.Algorithm)
+	}
+
+	u, _ = url.Parse("otpauth://totp/Example:alice?secret=" + secret + "&algorithm=SHA3-384")
+	if _, err := ParseOTPAuthURL(u); err == nil {
+		t.Error("expected error for unregistered algorithm")
+	}
+}
+
+func TestRegisteredAlgorithm_OCRA(t *testing.T) {
+	suite, err := NewRawSuite("OCRA-1:HOTP-SHA3-256-8:QN08")
+	if err != nil {
+		t.Fatalf("NewRawSuite failed: %v", err)
+	}
+	if suite.Config().Hash != testSHA3_256 || suite.Config().Digits != 8 {
+		t.Fatalf("unexpected config %+v", suite.Config())
+	}
+
+	challenge, _ := ParseDecimalChallengeRFC6287("12345678")
+	input := OCRAInput{Challenge: challenge}
+
+	code, err := GenerateOCRA(registryTestSecret, suite, input)
+	if err != nil {
+		t.Fatalf("GenerateOCRA failed: %v", err)
+	}
+	if code != "29186403" {
+		t.Errorf("GenerateOCRA = %s, want 29186403", code)
+	}
+	if ok, err := ValidateOCRA(registryTestSecret, code, suite, input); !ok || err != nil {
+		t.Errorf("ValidateOCRA = %v, %v", ok, err)
+	}
+
+	if _, err := NewRawSuite("OCRA-1:HOTP-SHA3-384-8:QN08"); err == nil {
+		t.Error("expected error for unregistered hash in suite")
+	}
+}
@@ -0,0 +1,354 @@
+package otp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CredentialType is the kind of one-time password a Credential produces.
+type CredentialType string
+
+const (
+	CredentialTOTP CredentialType = "totp"
+	CredentialHOTP CredentialType = "hotp"
+	CredentialOCRA CredentialType = "ocra"
+)
+
+// Credential is everything needed to generate, validate and enroll one HOTP, TOTP or
+// OCRA token, as a single value that can be passed around and persisted as JSON.
+//
+// Zero Digits, Period and Algorithm mean 6, 30 and SHA1. For OCRA credentials, Suite
+// defines the algorithm and digits, and Digits, Period, Algorithm, Counter and Encoder
+// are ignored.
+type Credential struct {
+	// Type is the kind of credential.
+	Type CredentialType
+
+	// Issuer is the name of the service, shown by authenticator apps.
+	Issuer string
+
+	// AccountName identifies the user, e.g. an email address.
+	AccountName string
+
+	// Secret is the base32-encoded shared secret.
+	Secret string
+
+	// Algorithm is the HMAC hash function.
+	Algorithm Algorithm
+
+	// Digits is the code length.
+	Digits Digits
+
+	// Period is the TOTP time step in seconds.
+	Period uint
+
+	// Counter is the next expected HOTP counter. Validate advances it.
+	Counter uint64
+
+	// Skew is the validation window in steps: on either side of the current time step
+	// for TOTP, ahead of Counter for HOTP.
+	Skew uint
+
+	// Encoder is the name of a non-decimal code encoder, e.g. "steam", or empty.
+	Encoder string
+
+	// Suite is the OCRA suite of OCRA credentials.
+	Suite Suite
+}
+
+// Param returns the Param for generating and validating codes of c. It returns
+// ErrUnsupportedEncoder if Encoder names no built-in encoder (see ParseEncoder),
+// except for OCRA credentials, whose Encoder is ignored.
+func (c *Credential) Param() (*Param, error) {
+	p := &Param{
+		Digits:    c.Digits,
+		Period:    c.Period,
+		Skew:      c.Skew,
+		Algorithm: c.Algorithm,
+	}
+	if p.Digits == 0 {
+		p.Digits = SixDigits
+	}
+	if p.Period == 0 {
+		p.Period = 30
+	}
+	if c.Encoder != "" && c.Type != CredentialOCRA {
+		enc, err := ParseEncoder(c.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		p.Encoder = enc
+	}
+	return p, nil
+}
+
+// Generate returns the TOTP code for the time step of t, or the HOTP code for Counter.
+// If t is zero, the current time is used. OCRA credentials need GenerateOCRA.
+func (c *Credential) Generate(t time.Time) (string, error) {
+	switch c.Type {
+	case CredentialTOTP:
+		param, err := c.Param()
+		if err != nil {
+			return "", err
+		}
+		return GenerateTOTP(c.Secret, t, param)
+	case CredentialHOTP:
+		param, err := c.Param()
+		if err != nil {
+			return "", err
+		}
+		return GenerateHOTP(c.Secret, c.Counter, param)
+	case CredentialOCRA:
+		return "", fmt.Errorf("OCRA credentials require an OCRAInput, use GenerateOCRA")
+	default:
+		return "", fmt.Errorf("unsupported credential type %q", c.Type)
+	}
+}
+
+// Validate checks code against the skew window around the time step of t (TOTP), or
+// against the counters [Counter, Counter+Skew] (HOTP), like HOTPVerifier. For HOTP, a
+// successful validation sets Counter past the matched counter, so the credential must
+// be persisted afterwards. OCRA credentials need ValidateOCRA.
+func (c *Credential) Validate(code string, t time.Time) (ValidationResult, error) {
+	switch c.Type {
+	case CredentialTOTP:
+		param, err := c.Param()
+		if err != nil {
+			return ValidationResult{}, err
+		}
+		return ValidateTOTPResult(c.Secret, code, t, param)
+	case CredentialHOTP:
+		param, err := c.Param()
+		if err != nil {
+			return ValidationResult{}, err
+		}
+		if param.Skew > 10 {
+			return ValidationResult{}, ErrInvalidSkew
+		}
+		secret, err := DecodeSecret(c.Secret)
+		if err != nil {
+			return ValidationResult{}, err
+		}
+		res, err := matchHOTPForward(secret, code, c.Counter, uint64(param.Skew), param)
+		if err != nil {
+			return ValidationResult{}, err
+		}
+		c.Counter = res.Counter + 1
+		return res, nil
+	case CredentialOCRA:
+		return ValidationResult{}, fmt.Errorf("OCRA credentials require an OCRAInput, use ValidateOCRA")
+	default:
+		return ValidationResult{}, fmt.Errorf("unsupported credential type %q", c.Type)
+	}
+}
+
+// GenerateOCRA returns the OCRA code of an OCRA credential for input.
+func (c *Credential) GenerateOCRA(input OCRAInput) (string, error) {
+	if err := c.checkOCRA(); err != nil {
+		return "", err
+	}
+	return GenerateOCRA(c.Secret, c.Suite, input)
+}
+
+// ValidateOCRA checks code against an OCRA credential for input.
+func (c *Credential) ValidateOCRA(code string, input OCRAInput) (bool, error) {
+	if err := c.checkOCRA(); err != nil {
+		return false, err
+	}
+	return ValidateOCRA(c.Secret, code, c.Suite, input)
+}
+
+func (c *Credential) checkOCRA() error {
+	if c.Type != CredentialOCRA {
+		return fmt.Errorf("not an OCRA credential: %q", c.Type)
+	}
+	if c.Suite == nil {
+		return fmt.Errorf("OCRA credential has no suite")
+	}
+	return nil
+}
+
+// URL returns the otpauth:// URL that enrolls c in an authenticator app.
+//
+// OCRA has no standard otpauth form; OCRA credentials use the non-standard
+// otpauth://ocra/LABEL?secret=...&suite=OCRA-1:... understood by ParseCredentialURL.
+func (c *Credential) URL() (*url.URL, error) {
+	p, err := c.Param()
+	if err != nil {
+		return nil, err
+	}
+	param := URLParam{
+		Issuer:      c.Issuer,
+		AccountName: c.AccountName,
+		Secret:      c.Secret,
+		Period:      p.Period,
+		Digits:      p.Digits,
+		Algorithm:   p.Algorithm,
+		Encoder:     c.Encoder,
+		Counter:     c.Counter,
+	}
+
+	switch c.Type {
+	case CredentialTOTP:
+		return GenerateTOTPURL(param)
+	case CredentialHOTP:
+		return GenerateHOTPURL(param)
+	case CredentialOCRA:
+		if err := c.checkOCRA(); err != nil {
+			return nil, err
+		}
+		cfg := c.Suite.Config()
+		param.Algorithm = cfg.Hash
+		param.Digits = Digits(cfg.Digits)
+		param.Encoder = ""
+		return generateOTPURL("ocra", param, map[string]string{
+			"suite": c.Suite.String(),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported credential type %q", c.Type)
+	}
+}
+
+// ParseCredentialURL parses an otpauth:// URL, as produced by Credential.URL or any
+// authenticator app, into a Credential.
+func ParseCredentialURL(u *url.URL) (*Credential, error) {
+	if u == nil {
+		return nil, fmt.Errorf("nil URL provided")
+	}
+
+	typ := CredentialType(strings.ToLower(u.Host))
+	if typ == CredentialOCRA {
+		// The common parameters are parsed like those of a TOTP URL.
+		totp := *u
+		totp.Host = string(CredentialTOTP)
+		u = &totp
+	}
+
+	param, err := ParseOTPAuthURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Credential{
+		Type:        CredentialTOTP,
+		Issuer:      param.Issuer,
+		AccountName: param.AccountName,
+		Secret:      param.Secret,
+		Algorithm:   param.Algorithm,
+		Digits:      param.Digits,
+		Period:      param.Period,
+		Encoder:     param.Encoder,
+	}
+
+	if typ != CredentialOCRA {
+		if _, err := ParseEncoder(c.Encoder); err != nil {
+			return nil, err
+		}
+	}
+
+	switch typ {
+	case CredentialHOTP:
+		c.Type = CredentialHOTP
+		c.Period = 0
+		c.Counter = param.Counter
+	case CredentialOCRA:
+		c.Type = CredentialOCRA
+		c.Algorithm, c.Digits, c.Period, c.Encoder = 0, 0, 0, ""
+		if c.Suite, err = NewRawSuite(u.Query().Get("suite")); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// credentialJSON is the JSON form of a Credential. The algorithm and suite are stored
+// by name, since Algorithm values depend on registration order.
+type credentialJSON struct {
+	Type        CredentialType `json:"type"`
+	Issuer      string         `json:"issuer,omitempty"`
+	AccountName string         `json:"account_name,omitempty"`
+	Secret      string         `json:"secret"`
+	Algorithm   string         `json:"algorithm,omitempty"`
+	Digits      Digits         `json:"digits,omitempty"`
+	Period      uint           `json:"period,omitempty"`
+	Counter     uint64         `json:"counter,omitempty"`
+	Skew        uint           `json:"skew,omitempty"`
+	Encoder     string         `json:"encoder,omitempty"`
+	Suite       string         `json:"suite,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Credential) MarshalJSON() ([]byte, error) {
+	v := credentialJSON{
+		Type:        c.Type,
+		Issuer:      c.Issuer,
+		AccountName: c.AccountName,
+		Secret:      c.Secret,
+		Digits:      c.Digits,
+		Period:      c.Period,
+		Counter:     c.Counter,
+		Skew:        c.Skew,
+		Encoder:     c.Encoder,
+	}
+	if c.Type != CredentialOCRA {
+		if _, err := lookupAlgorithm(c.Algorithm); err != nil {
+			return nil, err
+		}
+		v.Algorithm = c.Algorithm.String()
+	}
+	if c.Suite != nil {
+		v.Suite = c.Suite.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Credential) UnmarshalJSON(data []byte) error {
+	var v credentialJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v.Type {
+	case CredentialTOTP, CredentialHOTP, CredentialOCRA:
+	default:
+		return fmt.Errorf("unsupported credential type %q", v.Type)
+	}
+	if v.Type != CredentialOCRA {
+		if _, err := ParseEncoder(v.Encoder); err != nil {
+			return err
+		}
+	}
+
+	*c = Credential{
+		Type:        v.Type,
+		Issuer:      v.Issuer,
+		AccountName: v.AccountName,
+		Secret:      v.Secret,
+		Digits:      v.Digits,
+		Period:      v.Period,
+		Counter:     v.Counter,
+		Skew:        v.Skew,
+		Encoder:     v.Encoder,
+	}
+	if v.Algorithm != "" {
+		algo, err := ParseAlgorithm(v.Algorithm)
+		if err != nil {
+			return err
+		}
+		c.Algorithm = algo
+	}
+	if v.Suite != "" {
+		suite, err := NewRawSuite(v.Suite)
+		if err != nil {
+			return err
+		}
+		c.Suite = suite
+	}
+
+	return nil
+}
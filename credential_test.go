@@ -0,0 +1,212 @@
+package otp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const credentialTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCredential_TOTP(t *testing.T) {
+	c := &Credential{
+		Type:        CredentialTOTP,
+		Issuer:      "Example",
+		AccountName: "alice@example.com",
+		Secret:      credentialTestSecret,
+		Digits:      EightDigits,
+		Skew:        1,
+	}
+	now := time.Unix(1111111109, 0)
+
+	code, err := c.Generate(now)
+	if err != nil || code != "07081804" {
+		t.Fatalf("Generate = %s, %v; want 07081804", code, err)
+	}
+
+	earlier, _ := c.Generate(now.Add(-30 * time.Second))
+	res, err := c.Validate(earlier, now)
+	if err != nil || res.Offset != -1 {
+		t.Errorf("Validate = %+v, %v", res, err)
+	}
+	if _, err := c.Validate("00000000", now); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestCredential_HOTP(t *testing.T) {
+	c := &Credential{Type: CredentialHOTP, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Counter: 3, Skew: 2}
+
+	code, err := c.Generate(time.Time{})
+	if err != nil || code != "969429" {
+		t.Fatalf("Generate = %s, %v; want 969429 (RFC 4226 counter 3)", code, err)
+	}
+
+	// Counter 5 is within the window; Counter advances past it.
+	res, err := c.Validate("254676", time.Time{})
+	if err != nil || res.Counter != 5 || c.Counter != 6 {
+		t.Fatalf("Validate = %+v, %v; Counter = %d", res, err, c.Counter)
+	}
+	if _, err := c.Validate("254676", time.Time{}); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("replayed code: expected ErrInvalidCode, got %v", err)
+	}
+	if c.Counter != 6 {
+		t.Errorf("failed validation changed Counter to %d", c.Counter)
+	}
+}
+
+func TestCredential_OCRA(t *testing.T) {
+	c := &Credential{Type: CredentialOCRA, Secret: credentialTestSecret, Suite: MustRawSuite("OCRA-1:HOTP-SHA1-6:QN08")}
+
+	challenge, _ := ParseDecimalChallengeRFC6287("00000000")
+	input := OCRAInput{Challenge: challenge}
+
+	code, err := c.GenerateOCRA(input)
+	if err != nil || code != "237653" {
+		t.Fatalf("GenerateOCRA = %s, %v; want 237653 (RFC 6287 QN08)", code, err)
+	}
+	if ok, err := c.ValidateOCRA(code, input); !ok || err != nil {
+		t.Errorf("ValidateOCRA = %v, %v", ok, err)
+	}
+
+	if _, err := c.Generate(time.Now()); err == nil {
+		t.Error("Generate on an OCRA credential should fail")
+	}
+	if _, err := (&Credential{Type: CredentialTOTP}).GenerateOCRA(input); err == nil {
+		t.Error("GenerateOCRA on a TOTP credential should fail")
+	}
+}
+
+func TestCredential_URLRoundTrip(t *testing.T) {
+	tests := []*Credential{
+		{Type: CredentialTOTP, Issuer: "Example", AccountName: "alice@example.com", Secret: credentialTestSecret, Algorithm: SHA256, Digits: EightDigits, Period: 60},
+		{Type: CredentialTOTP, Issuer: "Steam", AccountName: "alice", Secret: credentialTestSecret, Digits: 5, Period: 30, Encoder: SteamEncoderName},
+		{Type: CredentialHOTP, Issuer: "Example", AccountName: "bob", Secret: credentialTestSecret, Algorithm: SHA1, Digits: SixDigits, Counter: 42},
+		{Type: CredentialOCRA, Issuer: "Bank", AccountName: "carol", Secret: credentialTestSecret, Suite: MustRawSuite("OCRA-1:HOTP-SHA256-8:QN08")},
+	}
+
+	for _, c := range tests {
+		t.Run(string(c.Type), func(t *testing.T) {
+			u, err := c.URL()
+			if err != nil {
+				t.Fatalf("URL failed: %v", err)
+			}
+			if u.Host != string(c.Type) {
+				t.Errorf("URL type = %s", u.Host)
+			}
+
+			parsed, err := ParseCredentialURL(u)
+			if err != nil {
+				t.Fatalf("ParseCredentialURL(%s) failed: %v", u, err)
+			}
+
+			want := *c
+			if want.Type != CredentialOCRA {
+				p, _ := c.Param()
+				want.Digits, want.Period = p.Digits, p.Period
+			}
+			if want.Type == CredentialHOTP {
+				want.Period = 0
+			}
+			if !credentialsEqual(parsed, &want) {
+				t.Errorf("round trip through %s:\n got %+v\nwant %+v", u, parsed, &want)
+			}
+		})
+	}
+
+	u, _ := url.Parse("otpauth://hotp/Example:bob?secret=" + credentialTestSecret + "&counter=x")
+	if _, err := ParseCredentialURL(u); err == nil {
+		t.Error("expected error for invalid counter")
+	}
+	u, _ = url.Parse("otpauth://ocra/Bank:carol?secret=" + credentialTestSecret + "&suite=OCRA-9")
+	if _, err := ParseCredentialURL(u); err == nil {
+		t.Error("expected error for invalid suite")
+	}
+	u, _ = url.Parse("otpauth://totp/A:b?secret=" + credentialTestSecret + "&encoder=base64")
+	if _, err := ParseCredentialURL(u); !errors.Is(err, ErrUnsupportedEncoder) {
+		t.Errorf("expected ErrUnsupportedEncoder, got %v", err)
+	}
+	u, _ = url.Parse("otpauth://totp/A:b?secret=" + credentialTestSecret + "&encoder=foo&digits=20")
+	if _, err := ParseCredentialURL(u); err == nil {
+		t.Error("expected error for unknown encoder with 20 digits")
+	}
+}
+
+func TestCredential_JSON(t *testing.T) {
+	tests := []*Credential{
+		{Type: CredentialTOTP, Issuer: "Example", AccountName: "alice", Secret: credentialTestSecret, Algorithm: SHA512, Digits: EightDigits, Period: 30, Skew: 1},
+		{Type: CredentialHOTP, Secret: credentialTestSecret, Counter: 7, Encoder: "base32"},
+		{Type: CredentialOCRA, Secret: credentialTestSecret, Suite: MustRawSuite("OCRA-1:HOTP-SHA1-6:QN08")},
+		{Type: CredentialTOTP, Secret: credentialTestSecret, Algorithm: testSHA3_256},
+	}
+
+	for _, c := range tests {
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+
+		var got Credential
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+		}
+		if !credentialsEqual(&got, c) {
+			t.Errorf("round trip through %s:\n got %+v\nwant %+v", data, &got, c)
+		}
+	}
+
+	data, _ := json.Marshal(Credential{Type: CredentialTOTP, Secret: credentialTestSecret, Algorithm: SHA256})
+	if !strings.Contains(string(data), `"algorithm":"SHA256"`) {
+		t.Errorf("algorithm must be stored by name: %s", data)
+	}
+
+	for _, bad := range []string{
+		`{"type":"sms","secret":"A"}`,
+		`{"type":"totp","secret":"A","algorithm":"MD4"}`,
+		`{"type":"ocra","secret":"A","suite":"OCRA-9"}`,
+		`{"type":"totp","secret":"A","encoder":"base64"}`,
+	} {
+		var c Credential
+		if err := json.Unmarshal([]byte(bad), &c); err == nil {
+			t.Errorf("Unmarshal(%s) should fail", bad)
+		}
+	}
+}
+
+func TestCredential_UnknownEncoder(t *testing.T) {
+	c := &Credential{Type: CredentialTOTP, Secret: credentialTestSecret, Digits: 20, Encoder: "foo"}
+	if _, err := c.Param(); !errors.Is(err, ErrUnsupportedEncoder) {
+		t.Errorf("Param: expected ErrUnsupportedEncoder, got %v", err)
+	}
+	if _, err := c.Generate(time.Now()); !errors.Is(err, ErrUnsupportedEncoder) {
+		t.Errorf("Generate: expected ErrUnsupportedEncoder, got %v", err)
+	}
+	if _, err := c.Validate("123456", time.Now()); !errors.Is(err, ErrUnsupportedEncoder) {
+		t.Errorf("Validate: expected ErrUnsupportedEncoder, got %v", err)
+	}
+	if _, err := c.URL(); !errors.Is(err, ErrUnsupportedEncoder) {
+		t.Errorf("URL: expected ErrUnsupportedEncoder, got %v", err)
+	}
+
+	// "decimal" names the default encoder.
+	c = &Credential{Type: CredentialTOTP, Secret: credentialTestSecret, Digits: EightDigits, Encoder: "decimal"}
+	if code, err := c.Generate(time.Unix(59, 0)); err != nil || code != "94287082" {
+		t.Errorf("decimal: Generate = %s, %v; want 94287082", code, err)
+	}
+}
+
+func credentialsEqual(a, b *Credential) bool {
+	suiteA, suiteB := "", ""
+	if a.Suite != nil {
+		suiteA = a.Suite.String()
+	}
+	if b.Suite != nil {
+		suiteB = b.Suite.String()
+	}
+	ac, bc := *a, *b
+	ac.Suite, bc.Suite = nil, nil
+	return ac == bc && suiteA == suiteB
+}
@@ -1,10 +1,6 @@
 package otp
 
 import (
-	"crypto/hmac"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
 	"hash"
 	"sync"
 	"unsafe"
@@ -13,6 +9,7 @@ import (
 const (
 	maskOffset   = 0x0F
 	mask31BitInt = 0x7FFFFFFF
+	mod31Bit     = 1 << 31
 	separator    = 0x00
 )
 
@@ -34,26 +31,6 @@ var (
 			return &buf
 		},
 	}
-	hmacPools = [...]hashPool{
-		{
-			pool: &sync.Pool{},
-			new: func(key []byte) hash.Hash {
-				return hmac.New(sha1.New, key)
-			},
-		},
-		{
-			pool: &sync.Pool{},
-			new: func(key []byte) hash.Hash {
-				return hmac.New(sha256.New, key)
-			},
-		},
-		{
-			pool: &sync.Pool{},
-			new: func(key []byte) hash.Hash {
-				return hmac.New(sha512.New, key)
-			},
-		},
-	}
 	mod10 = [...]uint64{
 		0, 10, 100, 1000, 10000, 100000, 1000000,
 		10000000, 100000000, 1000000000, 1000000000,
@@ -107,7 +84,11 @@ func formatDecimal(val uint32, digits int) string {
 }
 
 func truncate(sum []byte, mod uint64) uint32 {
-	offset := sum[len(sum)-1] & maskOffset
+	return truncateAt(sum, int(sum[len(sum)-1]&maskOffset), mod)
+}
+
+// truncateAt reads the 31-bit value at offset and reduces it modulo mod.
+func truncateAt(sum []byte, offset int, mod uint64) uint32 {
 	bin := (uint32(sum[offset]) << 24) |
 		(uint32(sum[offset+1]) << 16) |
 		(uint32(sum[offset+2]) << 8) |
@@ -6,20 +6,11 @@ import (
 
 // deriveRFC4226 is based on https://datatracker.ietf.org/doc/html/rfc4226
 func deriveRFC4226(secret []byte, counter uint64, digits int, algo Algorithm) (string, error) {
-	if int(algo) < 0 || int(algo) >= len(hmacPools) {
-		return "", ErrUnsupportedAlgorithm
+	sum, err := sumRFC4226(secret, counter, algo)
+	if err != nil {
+		return "", err
 	}
 
-	hp := &hmacPools[algo]
-	buf := rfc4226BufPool.Get().(*[8]byte)
-	binary.BigEndian.PutUint64(buf[:], counter)
-	defer rfc4226BufPool.Put(buf)
-
-	// Always create a new HMAC because Go doesn't support resetting the key.
-	mac := hp.new(secret)
-	mac.Write(buf[:])
-	sum := mac.Sum(nil)
-
 	// Dynamic truncation
 	otp := truncate(sum, mod10[digits])
 
@@ -29,3 +20,66 @@ func deriveRFC4226(secret []byte, counter uint64, digits int, algo Algorithm) (s
 
 	return longDigit(otp, digits), nil
 }
+
+// deriveRFC4226Ext is deriveRFC4226 with the options of the RFC 4226 reference
+// implementation (Appendix C): a fixed truncation offset and a trailing checksum digit.
+func deriveRFC4226Ext(secret []byte, counter uint64, digits int, algo Algorithm, checksum bool, trunc Truncation) (string, error) {
+	sum, err := sumRFC4226(secret, counter, algo)
+	if err != nil {
+		return "", err
+	}
+
+	otp := truncate(sum, mod10[digits])
+	if offset, ok := trunc.Offset(); ok {
+		if offset >= len(sum)-4 {
+			return "", ErrInvalidTruncation
+		}
+		otp = truncateAt(sum, offset, mod10[digits])
+	}
+
+	code := formatDecimal(otp, digits)
+	if checksum {
+		code += string(rune('0' + luhnChecksum(otp, digits)))
+	}
+
+	return code, nil
+}
+
+// luhnChecksum is calcChecksum of the RFC 4226 reference implementation: a Luhn check
+// digit over the low digits of num, doubling from the least significant digit.
+func luhnChecksum(num uint32, digits int) uint32 {
+	doubled := [...]uint32{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}
+
+	var total uint32
+	double := true
+	for ; digits > 0; digits-- {
+		d := num % 10
+		num /= 10
+		if double {
+			d = doubled[d]
+		}
+		total += d
+		double = !double
+	}
+
+	return (10 - total%10) % 10
+}
+
+// sumRFC4226 computes HMAC(secret, counter) with the counter as an 8-byte big-endian value.
+func sumRFC4226(secret []byte, counter uint64, algo Algorithm) ([]byte, error) {
+	spec, err := lookupAlgorithm(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	hp := &spec.hmac
+	buf := rfc4226BufPool.Get().(*[8]byte)
+	binary.BigEndian.PutUint64(buf[:], counter)
+	defer rfc4226BufPool.Put(buf)
+
+	// Always create a new HMAC because Go doesn't support resetting the key.
+	mac := hp.new(secret)
+	mac.Write(buf[:])
+
+	return mac.Sum(nil), nil
+}
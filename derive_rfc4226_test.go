@@ -126,3 +126,68 @@ func BenchmarkDeriveRFC4226(b *testing.B) {
 		}
 	}
 }
+
+// Vectors computed with the RFC 4226 reference implementation (Appendix C) with
+// addChecksum and truncationOffset set.
+func TestDeriveOTP_RFC4226_Options(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	tests := []struct {
+		name     string
+		digits   int
+		checksum bool
+		trunc    Truncation
+		expected []string
+	}{
+		{
+			name:     "checksum",
+			digits:   6,
+			checksum: true,
+			expected: []string{
+				"7552243", "2870822", "3591526", "9694290", "3383148",
+				"2546760", "2879229", "1625839", "3998713", "5204896",
+			},
+		},
+		{
+			name:     "offset 0",
+			digits:   6,
+			trunc:    FixedTruncation(0),
+			expected: []string{"755224", "717529", "868666"},
+		},
+		{
+			name:     "offset 15",
+			digits:   6,
+			trunc:    FixedTruncation(15),
+			expected: []string{"752228", "164019", "321279"},
+		},
+		{
+			name:     "checksum and offset 4",
+			digits:   8,
+			checksum: true,
+			trunc:    FixedTruncation(4),
+			expected: []string{"514558915", "226475523", "373591528"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for counter, want := range tt.expected {
+				got, err := deriveRFC4226Ext(secret, uint64(counter), tt.digits, SHA1, tt.checksum, tt.trunc)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != want {
+					t.Errorf("counter %d: got %s, want %s", counter, got, want)
+				}
+			}
+		})
+	}
+
+	// The reference implementation requires offset < len(HMAC) - 4.
+	if _, err := deriveRFC4226Ext(secret, 0, 6, SHA1, false, FixedTruncation(16)); err != ErrInvalidTruncation {
+		t.Errorf("expected ErrInvalidTruncation, got %v", err)
+	}
+	if _, err := deriveRFC4226Ext(secret, 0, 6, SHA256, false, FixedTruncation(16)); err != nil {
+		t.Errorf("offset 16 is valid for SHA256: %v", err)
+	}
+}
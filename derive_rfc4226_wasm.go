@@ -23,7 +23,15 @@ func DeriveRFC4226Wasm(secret []byte, counter uint64, digits int, algo Algorithm
 	case SHA512:
 		h = sha512.New
 	default:
-		return "", ErrUnsupportedAlgorithm
+		spec, err := lookupAlgorithm(algo)
+		if err != nil {
+			return "", err
+		}
+		mac := spec.hmac.new(secret)
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], counter)
+		mac.Write(buf[:])
+		return formatDecimal(truncate(mac.Sum(nil), pow10Wasm(digits)), digits), nil
 	}
 
 	var buf [8]byte
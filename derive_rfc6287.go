@@ -36,7 +36,12 @@ func deriveRFC6287(secret []byte, s Suite, input OCRAInput) (string, error) {
 		msg = append(msg, padBytes(input.Timestamp, 8)...) // 8 bytes
 	}
 
-	hp := &hmacPools[cfg.Hash]
+	spec, err := lookupAlgorithm(cfg.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	hp := &spec.hmac
 	mac := hp.new(secret)
 	mac.Write(msg)
 	sum := mac.Sum(nil)
@@ -0,0 +1,163 @@
+package otp
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultDriftConfig tracks up to 10 steps (5 minutes at a 30-second period) of drift,
+// giving each new observation half the weight of the estimate.
+var DefaultDriftConfig = &DriftConfig{
+	MaxDrift:  10,
+	Smoothing: 0.5,
+}
+
+// DriftConfig configures a DriftVerifier.
+type DriftConfig struct {
+	// MaxDrift bounds the estimated drift, in time steps, in either direction. The
+	// verifier never accepts codes further than MaxDrift+Skew steps from the server step.
+	// Zero means 10.
+	MaxDrift uint
+
+	// Smoothing is the weight, in (0, 1], of each observed offset in the exponentially
+	// smoothed drift estimate. Higher values follow clock changes faster but let a single
+	// outlier move the window further. Zero means 0.5.
+	Smoothing float64
+}
+
+func (c *DriftConfig) maxDrift() float64 {
+	if c.MaxDrift == 0 {
+		return float64(DefaultDriftConfig.MaxDrift)
+	}
+	return float64(c.MaxDrift)
+}
+
+func (c *DriftConfig) smoothing() float64 {
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		return DefaultDriftConfig.Smoothing
+	}
+	return c.Smoothing
+}
+
+// DriftStore persists the estimated clock drift of each credential, in time steps;
+// a positive drift means the client clock runs ahead of the server.
+//
+// Implementations must be safe for concurrent use. Unknown ids have a drift of 0.
+type DriftStore interface {
+	// Load returns the estimated drift of id.
+	Load(id string) (float64, error)
+
+	// Save records the estimated drift of id.
+	Save(id string, drift float64) error
+}
+
+// DriftVerifier validates TOTP codes with a skew window centered on each credential's
+// estimated clock drift instead of the server step.
+//
+// After every successful validation the observed offset is folded into an exponentially
+// smoothed estimate kept in a DriftStore, so that users whose device clock is off by a
+// few steps stay in the middle of the window and a small Skew (e.g. 1) is enough.
+// The estimate is bounded by DriftConfig.MaxDrift. Like ValidateTOTP, a DriftVerifier
+// does not reject replayed codes; record matched steps in a UsedCodeStore for that.
+type DriftVerifier struct {
+	store DriftStore
+	param Param
+	cfg   DriftConfig
+}
+
+// NewDriftVerifier returns a DriftVerifier using store for drift estimates.
+// If store is nil, a new in-memory store is used. If param is nil, DefaultTOTPParam is
+// used, and if cfg is nil, DefaultDriftConfig.
+func NewDriftVerifier(store DriftStore, param *Param, cfg *DriftConfig) *DriftVerifier {
+	if store == nil {
+		store = NewMemoryDriftStore()
+	}
+	if param == nil {
+		param = DefaultTOTPParam
+	}
+	if cfg == nil {
+		cfg = DefaultDriftConfig
+	}
+	return &DriftVerifier{store: store, param: *param, cfg: *cfg}
+}
+
+// ValidateTOTP checks code for the credential id against the skew window around the
+// time step of t shifted by the estimated drift of id, and on success updates the
+// estimate. The returned Offset is relative to the step of t, as in ValidateTOTPResult.
+// If t is zero, the current time from the Param's Clock is used.
+func (v *DriftVerifier) ValidateTOTP(id, secret, code string, t time.Time) (ValidationResult, error) {
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	drift, err := v.store.Load(id)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	drift = v.clamp(drift)
+	center := int64(math.Round(drift))
+
+	period := v.param.Period
+	if period == 0 {
+		period = 30
+	}
+	t = v.param.timeOrNow(t)
+
+	res, err := matchTOTP(secretBuf, code, t.Add(time.Duration(center)*time.Duration(period)*time.Second), &v.param)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	res.Offset += center
+
+	drift = v.clamp(drift + v.cfg.smoothing()*(float64(res.Offset)-drift))
+	if err := v.store.Save(id, drift); err != nil {
+		return ValidationResult{}, err
+	}
+
+	return res, nil
+}
+
+// Drift returns the estimated drift of id, in time steps.
+func (v *DriftVerifier) Drift(id string) (float64, error) {
+	drift, err := v.store.Load(id)
+	if err != nil {
+		return 0, err
+	}
+	return v.clamp(drift), nil
+}
+
+func (v *DriftVerifier) clamp(drift float64) float64 {
+	if math.IsNaN(drift) {
+		return 0
+	}
+	limit := v.cfg.maxDrift()
+	return math.Max(-limit, math.Min(limit, drift))
+}
+
+// MemoryDriftStore is an in-memory DriftStore.
+type MemoryDriftStore struct {
+	mu     sync.Mutex
+	drifts map[string]float64
+}
+
+// NewMemoryDriftStore returns an empty in-memory DriftStore.
+func NewMemoryDriftStore() *MemoryDriftStore {
+	return &MemoryDriftStore{drifts: make(map[string]float64)}
+}
+
+// Load implements DriftStore.
+func (s *MemoryDriftStore) Load(id string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drifts[id], nil
+}
+
+// Save implements DriftStore.
+func (s *MemoryDriftStore) Save(id string, drift float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drifts[id] = drift
+	return nil
+}
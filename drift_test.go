@@ -0,0 +1,85 @@
+package otp
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDriftVerifier(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1700000000, 0)
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+	v := NewDriftVerifier(nil, param, nil)
+
+	// The client clock drifts further ahead over time; the window follows it.
+	steps := []struct {
+		clientAhead int64
+		wantErr     error
+		wantDrift   float64
+	}{
+		{clientAhead: 1, wantDrift: 0.5},
+		{clientAhead: 2, wantDrift: 1.25},
+		{clientAhead: 2, wantDrift: 1.625},
+		{clientAhead: 3, wantDrift: 2.3125},
+		{clientAhead: 3, wantDrift: 2.65625},
+		{clientAhead: 0, wantErr: ErrInvalidCode, wantDrift: 2.65625},
+	}
+
+	for i, step := range steps {
+		at := now.Add(time.Duration(i) * time.Hour)
+		code, _ := GenerateTOTP(secret, at.Add(time.Duration(step.clientAhead)*30*time.Second), param)
+
+		res, err := v.ValidateTOTP("alice", secret, code, at)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("step %d: error = %v, want %v", i, err, step.wantErr)
+		}
+		if err == nil && (res.Offset != step.clientAhead || res.Counter != TimeCounterFunc(at, 30)+uint64(step.clientAhead)) {
+			t.Errorf("step %d: got %+v, want offset %d", i, res, step.clientAhead)
+		}
+		if drift, _ := v.Drift("alice"); drift != step.wantDrift {
+			t.Errorf("step %d: drift = %v, want %v", i, drift, step.wantDrift)
+		}
+	}
+
+	// Other credentials are unaffected.
+	code, _ := GenerateTOTP(secret, now, param)
+	if _, err := v.ValidateTOTP("bob", secret, code, now); err != nil {
+		t.Errorf("bob: %v", err)
+	}
+}
+
+func TestDriftVerifier_MaxDrift(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1700000000, 0)
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+
+	store := NewMemoryDriftStore()
+	v := NewDriftVerifier(store, param, &DriftConfig{MaxDrift: 2, Smoothing: 1})
+
+	_ = store.Save("alice", 50)
+	if drift, _ := v.Drift("alice"); drift != 2 {
+		t.Errorf("drift = %v, want clamped to 2", drift)
+	}
+	_ = store.Save("alice", math.NaN())
+	if drift, _ := v.Drift("alice"); drift != 0 {
+		t.Errorf("NaN drift = %v, want 0", drift)
+	}
+
+	// With Smoothing 1 the estimate jumps to each observation, but never beyond MaxDrift.
+	for ahead := int64(1); ahead <= 3; ahead++ {
+		code, _ := GenerateTOTP(secret, now.Add(time.Duration(ahead)*30*time.Second), param)
+		if _, err := v.ValidateTOTP("alice", secret, code, now); err != nil {
+			t.Fatalf("%d ahead: %v", ahead, err)
+		}
+	}
+	if drift, _ := v.Drift("alice"); drift != 2 {
+		t.Errorf("drift = %v, want 2", drift)
+	}
+
+	code, _ := GenerateTOTP(secret, now.Add(4*30*time.Second), param)
+	if _, err := v.ValidateTOTP("alice", secret, code, now); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("beyond MaxDrift+Skew: expected ErrInvalidCode, got %v", err)
+	}
+}
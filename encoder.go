@@ -0,0 +1,240 @@
+package otp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Encoder renders the HMAC output of a HOTP/TOTP computation as a code.
+//
+// The default, used when Param.Encoder is nil, is DecimalEncoder, which implements
+// RFC 4226 dynamic truncation and decimal formatting. Alphabet encoders produce
+// alphanumeric codes that carry more entropy per character, e.g. 8 base32 characters
+// carry 40 bits against 26.6 bits for 8 decimal digits.
+type Encoder interface {
+	// Encode renders the HMAC sum as a code of length characters.
+	Encode(sum []byte, length int) string
+
+	// Normalize canonicalizes user input before it is compared with an encoded code,
+	// e.g. by upper-casing it for case-insensitive alphabets.
+	Normalize(code string) string
+
+	// String returns the encoder name, as used in the otpauth `encoder` parameter.
+	String() string
+}
+
+var (
+	// DecimalEncoder is the RFC 4226 decimal encoder, supporting 1 to 10 digits.
+	DecimalEncoder Encoder = decimalEncoder{}
+
+	// SteamEncoder renders codes in the 26-character Steam Guard alphabet.
+	SteamEncoder Encoder = steamEncoder{}
+
+	// Base32Encoder renders codes in the RFC 4648 base32 alphabet (A-Z, 2-7).
+	Base32Encoder = MustAlphabetEncoder("base32", "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567")
+
+	// Base36Encoder renders codes in the alphabet 0-9, A-Z.
+	Base36Encoder = MustAlphabetEncoder("base36", "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+)
+
+// EncoderFromStr returns the built-in encoder with the given name.
+// It defaults to DecimalEncoder for empty or unknown names; use ParseEncoder for
+// untrusted input.
+func EncoderFromStr(name string) Encoder {
+	enc, err := ParseEncoder(name)
+	if err != nil {
+		return DecimalEncoder
+	}
+	return enc
+}
+
+// ParseEncoder returns the built-in encoder with the given name, case-insensitively.
+// The empty name and "decimal" mean DecimalEncoder. It returns ErrUnsupportedEncoder
+// for unknown names.
+func ParseEncoder(name string) (Encoder, error) {
+	switch strings.ToLower(name) {
+	case "", "decimal":
+		return DecimalEncoder, nil
+	case SteamEncoderName:
+		return SteamEncoder, nil
+	case "base32":
+		return Base32Encoder, nil
+	case "base36":
+		return Base36Encoder, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoder, name)
+	}
+}
+
+type decimalEncoder struct{}
+
+// Encode renders the RFC 4226 code. Lengths outside 1 to 10 produce an empty code,
+// which never validates; Param validation reports them as ErrInvalidDigits.
+func (decimalEncoder) Encode(sum []byte, length int) string {
+	if length < 1 || length >= len(mod10) {
+		return ""
+	}
+	otp := truncate(sum, mod10[length])
+	if length <= 8 {
+		return shortDigit(otp, length)
+	}
+	return longDigit(otp, length)
+}
+
+func (decimalEncoder) Normalize(code string) string {
+	return code
+}
+
+func (decimalEncoder) String() string {
+	return ""
+}
+
+type steamEncoder struct{}
+
+// Encode renders the full 31-bit truncated value, least significant character first,
+// as Steam Guard does.
+func (steamEncoder) Encode(sum []byte, length int) string {
+	v := truncate(sum, mod31Bit)
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = steamAlphabet[v%uint32(len(steamAlphabet))]
+		v /= uint32(len(steamAlphabet))
+	}
+	return string(out)
+}
+
+func (steamEncoder) Normalize(code string) string {
+	return strings.ToUpper(code)
+}
+
+func (steamEncoder) String() string {
+	return SteamEncoderName
+}
+
+// AlphabetEncoder renders codes in a custom alphabet.
+//
+// Unlike the decimal encoder, which is limited to the 31 bits of RFC 4226 dynamic
+// truncation, it reads a 63-bit value at the dynamic truncation offset (wrapping around
+// the end of the HMAC sum). The value is reduced modulo len(alphabet)^length and written
+// most significant character first. Codes longer than MaxLength would pad that value
+// with constant characters, so they are rejected with ErrInvalidDigits.
+type AlphabetEncoder struct {
+	name      string
+	alphabet  string
+	upperCase bool
+	maxLength int
+}
+
+// NewAlphabetEncoder returns an AlphabetEncoder named name using the given alphabet.
+// The alphabet must contain between 2 and 256 unique ASCII characters. If it contains
+// no lower-case letters, user input is upper-cased before comparison.
+func NewAlphabetEncoder(name, alphabet string) (*AlphabetEncoder, error) {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return nil, fmt.Errorf("%w: alphabet must have 2 to 256 characters", ErrInvalidAlphabet)
+	}
+
+	var seen [256]bool
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if c >= 0x80 {
+			return nil, fmt.Errorf("%w: non-ASCII character at %d", ErrInvalidAlphabet, i)
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("%w: duplicate character %q", ErrInvalidAlphabet, c)
+		}
+		seen[c] = true
+	}
+
+	// maxLength is the largest n with len(alphabet)^n <= 2^63.
+	base := uint64(len(alphabet))
+	maxLength := 0
+	for p := uint64(1); p <= (1<<63)/base; p *= base {
+		maxLength++
+	}
+
+	return &AlphabetEncoder{
+		name:      name,
+		alphabet:  alphabet,
+		upperCase: strings.ToUpper(alphabet) == alphabet,
+		maxLength: maxLength,
+	}, nil
+}
+
+// MustAlphabetEncoder is like NewAlphabetEncoder but panics if the alphabet is invalid.
+func MustAlphabetEncoder(name, alphabet string) *AlphabetEncoder {
+	e, err := NewAlphabetEncoder(name, alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// Encode implements Encoder.
+func (e *AlphabetEncoder) Encode(sum []byte, length int) string {
+	offset := int(sum[len(sum)-1] & maskOffset)
+
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<8 | uint64(sum[(offset+i)%len(sum)])
+	}
+	v &= 1<<63 - 1
+
+	base := uint64(len(e.alphabet))
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = e.alphabet[v%base]
+		v /= base
+	}
+	return string(out)
+}
+
+// MaxLength returns the longest code e can render from the 63-bit value it reads, i.e.
+// the largest length with len(alphabet)^length <= 2^63.
+func (e *AlphabetEncoder) MaxLength() int {
+	return e.maxLength
+}
+
+// Normalize implements Encoder.
+func (e *AlphabetEncoder) Normalize(code string) string {
+	if e.upperCase {
+		return strings.ToUpper(code)
+	}
+	return code
+}
+
+// String implements Encoder.
+func (e *AlphabetEncoder) String() string {
+	return e.name
+}
+
+// deriveCode derives the code for counter according to param, honoring its Encoder.
+func deriveCode(secret []byte, counter uint64, param *Param) (string, error) {
+	if !param.validDigits() {
+		return "", ErrInvalidDigits
+	}
+
+	digits := param.Digits.Int()
+	if param.decimal() {
+		if param.Checksum || param.Truncation != DynamicTruncation {
+			return deriveRFC4226Ext(secret, counter, digits, param.Algorithm, param.Checksum, param.Truncation)
+		}
+		return deriveRFC4226(secret, counter, digits, param.Algorithm)
+	}
+
+	sum, err := sumRFC4226(secret, counter, param.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	return param.Encoder.Encode(sum, digits), nil
+}
+
+// validateCode is the Encoder-aware counterpart of validateRFC4226.
+func validateCode(code string, secret []byte, counter uint64, param *Param) (bool, error) {
+	if !param.decimal() {
+		code = param.Encoder.Normalize(code)
+	}
+	return validate(code, param.codeLength(), func() (string, error) {
+		return deriveCode(secret, counter, param)
+	})
+}
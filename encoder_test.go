@@ -0,0 +1,226 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecimalEncoder_MatchesRFC4226(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	for counter := uint64(0); counter < 10; counter++ {
+		for _, digits := range []int{6, 8, 10} {
+			want, err := deriveRFC4226(secret, counter, digits, SHA1)
+			if err != nil {
+				t.Fatalf("deriveRFC4226 failed: %v", err)
+			}
+
+			got, err := deriveCode(secret, counter, &Param{Digits: Digits(digits), Algorithm: SHA1, Encoder: DecimalEncoder})
+			if err != nil {
+				t.Fatalf("deriveCode failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("counter %d digits %d: got %s, want %s", counter, digits, got, want)
+			}
+		}
+	}
+}
+
+func TestAlphabetEncoders(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+	custom := MustAlphabetEncoder("hex", "0123456789abcdef")
+
+	tests := []struct {
+		name    string
+		encoder Encoder
+		length  Digits
+		charset string
+	}{
+		{"base32", Base32Encoder, 8, "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"},
+		{"base36", Base36Encoder, 8, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+		{"base36 long", Base36Encoder, 12, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+		{"custom", custom, 10, "0123456789abcdef"},
+		{"steam", SteamEncoder, 5, steamAlphabet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param := &Param{Digits: tt.length, Period: 30, Skew: 1, Algorithm: SHA256, Encoder: tt.encoder}
+			s256, _ := RandomSecret(SHA256)
+
+			for _, sec := range []string{secret, s256} {
+				code, err := GenerateTOTP(sec, now, param)
+				if err != nil {
+					t.Fatalf("GenerateTOTP failed: %v", err)
+				}
+				if len(code) != tt.length.Int() {
+					t.Errorf("code %q has length %d, want %d", code, len(code), tt.length)
+				}
+				for _, c := range code {
+					if !strings.ContainsRune(tt.charset, c) {
+						t.Errorf("code %q contains %q outside the alphabet", code, c)
+					}
+				}
+
+				ok, err := ValidateTOTP(sec, code, now, param)
+				if err != nil || !ok {
+					t.Errorf("ValidateTOTP(%q) = %v, %v", code, ok, err)
+				}
+
+				hotp, err := GenerateHOTP(sec, 7, param)
+				if err != nil {
+					t.Fatalf("GenerateHOTP failed: %v", err)
+				}
+				if ok, err := ValidateHOTP(sec, hotp, 7, param); err != nil || !ok {
+					t.Errorf("ValidateHOTP(%q) = %v, %v", hotp, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAlphabetEncoder_CaseInsensitive(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	param := &Param{Digits: 8, Algorithm: SHA1, Encoder: Base32Encoder}
+
+	code, err := GenerateHOTP(secret, 1, param)
+	if err != nil {
+		t.Fatalf("GenerateHOTP failed: %v", err)
+	}
+	if ok, err := ValidateHOTP(secret, strings.ToLower(code), 1, param); !ok || err != nil {
+		t.Errorf("expected lower-case input to validate, got %v, %v", ok, err)
+	}
+
+	caseSensitive := &Param{Digits: 8, Algorithm: SHA1, Encoder: MustAlphabetEncoder("mixed", "abcdefghABCDEFGH")}
+	code, err = GenerateHOTP(secret, 1, caseSensitive)
+	if err != nil {
+		t.Fatalf("GenerateHOTP failed: %v", err)
+	}
+	if strings.ToUpper(code) != code {
+		if ok, _ := ValidateHOTP(secret, strings.ToUpper(code), 1, caseSensitive); ok {
+			t.Errorf("expected case-sensitive alphabet to reject upper-cased %q", code)
+		}
+	}
+}
+
+func TestAlphabetEncoder_MaxLength(t *testing.T) {
+	tests := []struct {
+		enc  *AlphabetEncoder
+		want int
+	}{
+		{MustAlphabetEncoder("bin", "01"), 63},
+		{MustAlphabetEncoder("hex", "0123456789abcdef"), 15},
+		{Base32Encoder, 12},
+		{Base36Encoder, 12},
+	}
+	for _, tt := range tests {
+		if got := tt.enc.MaxLength(); got != tt.want {
+			t.Errorf("%s.MaxLength() = %d, want %d", tt.enc, got, tt.want)
+		}
+	}
+
+	param := &Param{Digits: 13, Algorithm: SHA1, Encoder: Base32Encoder}
+	if _, err := NewKey("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", param); !errors.Is(err, ErrInvalidDigits) {
+		t.Errorf("NewKey with 13 base32 characters = %v, want ErrInvalidDigits", err)
+	}
+}
+
+func TestNewAlphabetEncoder_Invalid(t *testing.T) {
+	for _, alphabet := range []string{"", "a", "aba", "ab\xff"} {
+		if _, err := NewAlphabetEncoder("x", alphabet); !errors.Is(err, ErrInvalidAlphabet) {
+			t.Errorf("NewAlphabetEncoder(%q) = %v, want ErrInvalidAlphabet", alphabet, err)
+		}
+	}
+}
+
+func TestEncoderFromStr(t *testing.T) {
+	tests := map[string]Encoder{
+		"":        DecimalEncoder,
+		"unknown": DecimalEncoder,
+		"steam":   SteamEncoder,
+		"STEAM":   SteamEncoder,
+		"base32":  Base32Encoder,
+		"base36":  Base36Encoder,
+	}
+	for name, want := range tests {
+		if got := EncoderFromStr(name); got != want {
+			t.Errorf("EncoderFromStr(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParseEncoder(t *testing.T) {
+	tests := map[string]Encoder{
+		"":        DecimalEncoder,
+		"decimal": DecimalEncoder,
+		"Steam":   SteamEncoder,
+		"base32":  Base32Encoder,
+		"BASE36":  Base36Encoder,
+	}
+	for name, want := range tests {
+		if got, err := ParseEncoder(name); got != want || err != nil {
+			t.Errorf("ParseEncoder(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"unknown", "base64", "decimal "} {
+		if _, err := ParseEncoder(name); !errors.Is(err, ErrUnsupportedEncoder) {
+			t.Errorf("ParseEncoder(%q) = %v, want ErrUnsupportedEncoder", name, err)
+		}
+	}
+}
+
+func TestDeriveCode_InvalidDigits(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for _, p := range []*Param{
+		{Digits: 0, Algorithm: SHA1},
+		{Digits: 11, Algorithm: SHA1},
+		{Digits: 0, Algorithm: SHA1, Encoder: Base32Encoder},
+		{Digits: 11, Algorithm: SHA1, Encoder: DecimalEncoder},
+		{Digits: 12, Algorithm: SHA1, Encoder: DecimalEncoder},
+		{Digits: 13, Algorithm: SHA1, Encoder: Base32Encoder},
+		{Digits: 16, Algorithm: SHA1, Encoder: MustAlphabetEncoder("hex", "0123456789abcdef")},
+	} {
+		if _, err := deriveCode(secret, 0, p); !errors.Is(err, ErrInvalidDigits) {
+			t.Errorf("deriveCode(%+v) = %v, want ErrInvalidDigits", p, err)
+		}
+	}
+
+	if _, err := GenerateTOTP("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0), &Param{Digits: 12, Period: 30, Algorithm: SHA1, Encoder: DecimalEncoder}); !errors.Is(err, ErrInvalidDigits) {
+		t.Errorf("GenerateTOTP with 12 decimal digits = %v, want ErrInvalidDigits", err)
+	}
+	if code := DecimalEncoder.Encode(make([]byte, 20), 12); code != "" {
+		t.Errorf("DecimalEncoder.Encode with 12 digits = %q, want empty", code)
+	}
+}
+
+func TestDeriveCode_ExplicitDecimalEncoder(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	// An explicit DecimalEncoder honors Checksum and Truncation like a nil Encoder.
+	for _, p := range []Param{
+		{Digits: SixDigits, Algorithm: SHA1, Checksum: true},
+		{Digits: SixDigits, Algorithm: SHA1, Truncation: FixedTruncation(4)},
+	} {
+		want, err := deriveCode(secret, 0, &p)
+		if err != nil {
+			t.Fatalf("deriveCode failed: %v", err)
+		}
+
+		p.Encoder = DecimalEncoder
+		got, err := deriveCode(secret, 0, &p)
+		if err != nil || got != want {
+			t.Errorf("deriveCode(%+v) = %s, %v; want %s", p, got, err, want)
+		}
+		if ok, err := validateCode(want, secret, 0, &p); !ok || err != nil {
+			t.Errorf("validateCode(%+v) = %v, %v", p, ok, err)
+		}
+	}
+
+	p := &Param{Digits: SixDigits, Algorithm: SHA1, Checksum: true, Encoder: DecimalEncoder}
+	if code, _ := deriveCode(secret, 0, p); code != "7552243" {
+		t.Errorf("checksum code = %s, want 7552243", code)
+	}
+}
@@ -11,4 +11,30 @@ var (
 	ErrSecretRequired       = errors.New("secret is required")
 	ErrInvalidSkew          = errors.New("invalid skew, a larger Skew increases the chance of a brute-force hit")
 	ErrInvalidRawSuite      = errors.New("invalid OCRA suite string")
+	ErrCodeReused           = errors.New("otp code already used")
+	ErrResyncCodesRequired  = errors.New("resync requires at least two consecutive codes")
+	ErrInvalidLookAhead     = errors.New("invalid look-ahead window")
+	ErrLocked               = errors.New("credential is locked after too many failed attempts")
+	ErrThrottled            = errors.New("too many failed attempts")
+	ErrInvalidSealedSecret  = errors.New("invalid or tampered sealed secret")
+	ErrUnknownKeyID         = errors.New("unknown key encryption key id")
+	ErrMasterKeyTooShort    = errors.New("master key is too short")
+	ErrInvalidDigits        = errors.New("invalid digits")
+	ErrInvalidAlphabet      = errors.New("invalid code alphabet")
+	ErrInvalidSKeyResponse  = errors.New("invalid S/KEY response")
+	ErrInvalidSKeyChallenge = errors.New("invalid S/KEY challenge")
+	ErrInvalidSKeySeed      = errors.New("invalid S/KEY seed")
+	ErrSKeyExhausted        = errors.New("S/KEY sequence exhausted")
+	ErrInvalidYubicoOTP     = errors.New("invalid Yubico OTP")
+	ErrUnknownYubiKey       = errors.New("unknown YubiKey public id")
+	ErrInvalidYKValResponse = errors.New("invalid YK-VAL response")
+	ErrYKValStatus          = errors.New("YK-VAL verification failed")
+	ErrInvalidTruncation    = errors.New("truncation offset out of range for the HMAC size")
+	ErrAlgorithmRegistered  = errors.New("algorithm already registered")
+	ErrTimeBeforeT0         = errors.New("time is before T0")
+	ErrInvalidRecoveryCode  = errors.New("invalid recovery code")
+	ErrCodeExpired          = errors.New("otp code expired")
+	ErrNoActiveSecret       = errors.New("no active secret version")
+	ErrIssuerMismatch       = errors.New("issuer parameter does not match the label issuer")
+	ErrUnsupportedEncoder   = errors.New("unsupported encoder")
 )
@@ -2,6 +2,7 @@ package otp
 
 import (
 	"net/url"
+	"strconv"
 )
 
 // DefaultHOTPParam provides a default configuration for HOTP generation and validation,
@@ -26,17 +27,17 @@ func GenerateHOTP(secret string, counter uint64, param *Param) (string, error) {
 		return "", err
 	}
 
-	return deriveRFC4226(secretBuf, counter, param.Digits.Int(), param.Algorithm)
+	return deriveCode(secretBuf, counter, param)
 }
 
 // GenerateHOTPURL constructs an otpauth:// URL for configuring HOTP-based authenticators.
-// The URL includes the issuer, account name, secret, algorithm, digits, and counter.
+// The URL includes the issuer, account name, secret, algorithm, digits, and initial counter.
 //
 // Example output:
 // otpauth://hotp/Example:alice@domain.com?secret=BASE32ENCODEDSECRET&issuer=Example&algorithm=SHA1&digits=6&counter=0
 func GenerateHOTPURL(param URLParam) (*url.URL, error) {
 	return generateOTPURL("hotp", param, map[string]string{
-		"counter": "0",
+		"counter": strconv.FormatUint(param.Counter, 10),
 	})
 }
 
@@ -44,37 +45,167 @@ func GenerateHOTPURL(param URLParam) (*url.URL, error) {
 // Returns true if valid, false otherwise. Uses constant-time comparison internally.
 // If `param` is nil, DefaultHOTPParam is used.
 func ValidateHOTP(secret, code string, counter uint64, param *Param) (bool, error) {
+	if _, err := ValidateHOTPResult(secret, code, counter, param); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidateHOTPResult is like ValidateHOTP but reports which counter of the skew
+// window matched. Callers should persist Counter+1 as the next expected counter.
+// If `param` is nil, DefaultHOTPParam is used.
+func ValidateHOTPResult(secret, code string, counter uint64, param *Param) (ValidationResult, error) {
 	if param == nil {
 		def := *DefaultHOTPParam
 		param = &def
 	}
 
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	return matchHOTP(secretBuf, code, counter, param)
+}
+
+// matchHOTP walks the symmetric skew window around counter and returns the
+// first counter whose code matches.
+func matchHOTP(secret []byte, code string, counter uint64, param *Param) (ValidationResult, error) {
 	if param.Skew > 10 {
-		return false, ErrInvalidSkew
+		return ValidationResult{}, ErrInvalidSkew
 	}
-	skew := int64(param.Skew)
 
-	secretBuf, err := DecodeSecret(secret)
-	if err != nil {
-		return false, err
+	return matchCounterWindow(counter, param, func(c uint64) (bool, error) {
+		return validateCode(code, secret, c, param)
+	})
+}
+
+// matchCounterWindow calls validateFn for every counter of the symmetric skew window
+// around counter, from the oldest to the newest, and returns the first one it accepts.
+// With param.ConstantTime, every counter is evaluated whatever the outcome.
+func matchCounterWindow(counter uint64, param *Param, validateFn func(counter uint64) (bool, error)) (ValidationResult, error) {
+	c, offset, ok := scanWindow(counter, param.Skew, param.ConstantTime, validateFn)
+	if !ok {
+		return ValidationResult{}, ErrInvalidCode
 	}
+	return ValidationResult{Counter: c, Offset: offset}, nil
+}
 
-	for i := -skew; i <= skew; i++ {
-		var c uint64
-		if i < 0 {
-			if int64(counter) < -i {
-				continue // prevent underflow
+// scanWindow calls validateFn for the counters counter-skew..counter+skew, skipping those
+// below zero, and returns the first accepted counter and its offset from counter.
+func scanWindow(counter uint64, skew uint, constantTime bool, validateFn func(counter uint64) (bool, error)) (uint64, int64, bool) {
+	return scanRange(counter, -int64(skew), int64(skew), constantTime, validateFn)
+}
+
+// scanRange calls validateFn for the counters counter+lo..counter+hi, skipping those that
+// would wrap around, and returns the first accepted counter and its offset from counter.
+//
+// In constant-time mode it never returns early: every step is evaluated and the first
+// match is selected with masks rather than branches, so the time taken depends only on
+// the range and counter, not on whether or where the code matched.
+func scanRange(counter uint64, lo, hi int64, constantTime bool, validateFn func(counter uint64) (bool, error)) (uint64, int64, bool) {
+	var found, matched, offset uint64
+	for i := lo; i <= hi; i++ {
+		if i < 0 && int64(counter) < -i {
+			continue // prevent underflow
+		}
+		c := counter + uint64(i)
+		if i > 0 && c < counter {
+			continue // prevent overflow
+		}
+
+		valid, err := validateFn(c)
+		if !constantTime {
+			if err == nil && valid {
+				return c, i, true
 			}
-			c = counter - uint64(-i)
-		} else {
-			c = counter + uint64(i)
+			continue
 		}
 
-		valid, err := validateRFC4226(code, secretBuf, c, param.Digits, param.Algorithm)
-		if err == nil && valid {
-			return true, nil
+		// A validateFn never reports valid together with an error.
+		take := uint64(boolByte(valid)) &^ found
+		mask := -take
+		matched = matched&^mask | c&mask
+		offset = offset&^mask | uint64(i)&mask
+		found |= take
+	}
+
+	return matched, int64(offset), found == 1
+}
+
+// boolByte converts b to 0 or 1; the compiler emits a flag move, not a branch.
+func boolByte(b bool) uint8 {
+	var v uint8
+	if b {
+		v = 1
+	}
+	return v
+}
+
+// DefaultHOTPLookAhead is the look-ahead window used by ResyncHOTP when none is given.
+const DefaultHOTPLookAhead = 100
+
+// MaxHOTPLookAhead bounds the look-ahead window accepted by ResyncHOTP.
+const MaxHOTPLookAhead = 10000
+
+// ResyncHOTP resynchronizes an HOTP counter as described in RFC 4226 §7.4.
+//
+// Unlike ValidateHOTP, the search is forward-only: it scans counters from counter up to
+// counter+lookAhead for the first code, and only accepts the match when every following
+// code in codes matches the next consecutive counter. At least two codes are required,
+// which makes a lucky guess within the large window negligible.
+//
+// On success the returned Counter is the counter of the last code; callers should
+// persist Counter+1 as the next expected counter. Offset is the distance from counter
+// to the first code. A lookAhead of 0 uses DefaultHOTPLookAhead.
+// If `param` is nil, DefaultHOTPParam is used; its Skew is ignored.
+func ResyncHOTP(secret string, codes []string, counter uint64, lookAhead uint, param *Param) (ValidationResult, error) {
+	if param == nil {
+		def := *DefaultHOTPParam
+		param = &def
+	}
+
+	if len(codes) < 2 {
+		return ValidationResult{}, ErrResyncCodesRequired
+	}
+	if lookAhead == 0 {
+		lookAhead = DefaultHOTPLookAhead
+	}
+	if lookAhead > MaxHOTPLookAhead {
+		return ValidationResult{}, ErrInvalidLookAhead
+	}
+
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	n := uint64(len(codes) - 1)
+	c, offset, ok := scanRange(counter, 0, int64(lookAhead), param.ConstantTime, func(c uint64) (bool, error) {
+		if c+n < c {
+			return false, nil // prevent overflow
 		}
+		return matchConsecutive(secretBuf, codes, c, param), nil
+	})
+	if !ok {
+		return ValidationResult{}, ErrInvalidCode
 	}
 
-	return false, ErrInvalidCode
+	return ValidationResult{Counter: c + n, Offset: offset}, nil
+}
+
+// matchConsecutive reports whether codes match the counters starting at counter, in order.
+// With param.ConstantTime, every code is evaluated whatever the outcome.
+func matchConsecutive(secret []byte, codes []string, counter uint64, param *Param) bool {
+	match := true
+	for j, code := range codes {
+		valid, err := validateCode(code, secret, counter+uint64(j), param)
+		if err != nil || !valid {
+			if !param.ConstantTime {
+				return false
+			}
+			match = false
+		}
+	}
+	return match
 }
@@ -110,3 +110,121 @@ func TestGenerateHOTPURL(t *testing.T) {
 		t.Errorf("Invalid otpauth URL: %v", err)
 	}
 }
+
+func TestValidateHOTPResult(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name    string
+		code    string
+		counter uint64
+		want    uint64
+		offset  int64
+		wantErr error
+	}{
+		{"exact", "359152", 2, 2, 0, nil},
+		{"behind", "287082", 2, 1, -1, nil},
+		{"ahead", "338314", 2, 4, 2, nil},
+		{"near zero", "755224", 1, 0, -1, nil},
+		{"outside window", "520489", 2, 0, 0, ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ValidateHOTPResult(secret, tt.code, tt.counter, nil)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Counter != tt.want || res.Offset != tt.offset {
+				t.Errorf("got counter=%d offset=%d, want counter=%d offset=%d", res.Counter, res.Offset, tt.want, tt.offset)
+			}
+			if !res.StepStart.IsZero() || !res.StepEnd.IsZero() {
+				t.Errorf("expected zero step boundaries for HOTP")
+			}
+		})
+	}
+}
+
+func TestResyncHOTP(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	// Token pressed far beyond the ValidateHOTP skew window.
+	const server, token = 3, 60
+
+	gen := func(c uint64) string {
+		code, err := GenerateHOTP(secret, c, nil)
+		if err != nil {
+			t.Fatalf("GenerateHOTP failed: %v", err)
+		}
+		return code
+	}
+
+	if ok, _ := ValidateHOTP(secret, gen(token), server, nil); ok {
+		t.Fatalf("expected plain validation to fail outside skew window")
+	}
+
+	tests := []struct {
+		name      string
+		codes     []string
+		lookAhead uint
+		want      uint64
+		wantErr   error
+	}{
+		{"two consecutive", []string{gen(token), gen(token + 1)}, 0, token + 1, nil},
+		{"three consecutive", []string{gen(token), gen(token + 1), gen(token + 2)}, 0, token + 2, nil},
+		{"not consecutive", []string{gen(token), gen(token + 2)}, 0, 0, ErrInvalidCode},
+		{"backwards", []string{gen(server - 2), gen(server - 1)}, 0, 0, ErrInvalidCode},
+		{"window too small", []string{gen(token), gen(token + 1)}, 10, 0, ErrInvalidCode},
+		{"single code", []string{gen(token)}, 0, 0, ErrResyncCodesRequired},
+		{"window too large", []string{gen(token), gen(token + 1)}, MaxHOTPLookAhead + 1, 0, ErrInvalidLookAhead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ResyncHOTP(secret, tt.codes, server, tt.lookAhead, nil)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Counter != tt.want {
+				t.Errorf("Counter = %d, want %d", res.Counter, tt.want)
+			}
+			if res.Offset != token-server {
+				t.Errorf("Offset = %d, want %d", res.Offset, token-server)
+			}
+		})
+	}
+}
+
+func TestHOTP_ChecksumAndTruncation(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	param := &Param{Digits: SixDigits, Skew: 1, Algorithm: SHA1, Checksum: true}
+
+	code, err := GenerateHOTP(secret, 1, param)
+	if err != nil || code != "2870822" {
+		t.Fatalf("GenerateHOTP = %s, %v; want 2870822", code, err)
+	}
+
+	if ok, err := ValidateHOTP(secret, "2870822", 1, param); !ok || err != nil {
+		t.Errorf("ValidateHOTP with checksum = %v, %v", ok, err)
+	}
+	if ok, _ := ValidateHOTP(secret, "287082", 1, param); ok {
+		t.Error("code without check digit must be rejected")
+	}
+	if ok, _ := ValidateHOTP(secret, "2870823", 1, param); ok {
+		t.Error("code with wrong check digit must be rejected")
+	}
+
+	param = &Param{Digits: SixDigits, Algorithm: SHA1, Truncation: FixedTruncation(15)}
+	code, err = GenerateHOTP(secret, 2, param)
+	if err != nil || code != "321279" {
+		t.Fatalf("GenerateHOTP = %s, %v; want 321279", code, err)
+	}
+	if ok, err := ValidateHOTP(secret, code, 2, param); !ok || err != nil {
+		t.Errorf("ValidateHOTP with fixed truncation = %v, %v", ok, err)
+	}
+}
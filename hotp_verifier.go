@@ -0,0 +1,153 @@
+package otp
+
+import "sync"
+
+// CounterStore persists the next expected HOTP counter per credential.
+//
+// Implementations must be safe for concurrent use. CompareAndSwap must be atomic,
+// e.g. an `UPDATE ... SET counter = new WHERE id = ? AND counter = old` in SQL.
+type CounterStore interface {
+	// Load returns the next expected counter for the credential id.
+	Load(id string) (uint64, error)
+
+	// CompareAndSwap sets the counter of id to new only if it currently equals old,
+	// and reports whether the swap happened.
+	CompareAndSwap(id string, old, new uint64) (bool, error)
+}
+
+// HOTPVerifier validates HOTP codes against a counter kept in a CounterStore
+// and advances it after every successful validation.
+//
+// Matching is forward-only: codes are accepted for counters in [counter, counter+Skew],
+// so a code for an already consumed counter can never be accepted again. Concurrent
+// submissions of the same code race on CompareAndSwap, and only one of them succeeds;
+// the others fail with ErrCodeReused.
+type HOTPVerifier struct {
+	store CounterStore
+	param Param
+}
+
+// NewHOTPVerifier returns an HOTPVerifier using store for counters.
+// If store is nil, a new in-memory store is used. If `param` is nil, DefaultHOTPParam is used.
+func NewHOTPVerifier(store CounterStore, param *Param) *HOTPVerifier {
+	if store == nil {
+		store = NewMemoryCounterStore()
+	}
+	if param == nil {
+		param = DefaultHOTPParam
+	}
+	return &HOTPVerifier{store: store, param: *param}
+}
+
+// Validate checks code for the credential id and, on success, advances the stored counter
+// past the matched one. The returned Offset is relative to the stored counter.
+func (v *HOTPVerifier) Validate(id, secret, code string) (ValidationResult, error) {
+	if v.param.Skew > 10 {
+		return ValidationResult{}, ErrInvalidSkew
+	}
+
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	matchedBefore := false
+	for {
+		counter, err := v.store.Load(id)
+		if err != nil {
+			return ValidationResult{}, err
+		}
+
+		res, err := matchHOTPForward(secretBuf, code, counter, uint64(v.param.Skew), &v.param)
+		if err != nil {
+			if matchedBefore {
+				// Another submission consumed the counter this code matched.
+				return ValidationResult{}, ErrCodeReused
+			}
+			return ValidationResult{}, err
+		}
+		matchedBefore = true
+
+		swapped, err := v.store.CompareAndSwap(id, counter, res.Counter+1)
+		if err != nil {
+			return ValidationResult{}, err
+		}
+		if swapped {
+			return res, nil
+		}
+	}
+}
+
+// Resync runs ResyncHOTP from the stored counter and, on success, advances it past the
+// last of the consecutive codes.
+func (v *HOTPVerifier) Resync(id, secret string, codes []string, lookAhead uint) (ValidationResult, error) {
+	counter, err := v.store.Load(id)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	res, err := ResyncHOTP(secret, codes, counter, lookAhead, &v.param)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	swapped, err := v.store.CompareAndSwap(id, counter, res.Counter+1)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+	if !swapped {
+		return ValidationResult{}, ErrCodeReused
+	}
+
+	return res, nil
+}
+
+// matchHOTPForward returns the first counter in [counter, counter+window] whose code matches.
+// With param.ConstantTime, every counter is evaluated whatever the outcome.
+func matchHOTPForward(secret []byte, code string, counter, window uint64, param *Param) (ValidationResult, error) {
+	c, offset, ok := scanRange(counter, 0, int64(window), param.ConstantTime, func(c uint64) (bool, error) {
+		return validateCode(code, secret, c, param)
+	})
+	if !ok {
+		return ValidationResult{}, ErrInvalidCode
+	}
+	return ValidationResult{Counter: c, Offset: offset}, nil
+}
+
+// MemoryCounterStore is an in-memory CounterStore. Unknown ids start at counter 0.
+type MemoryCounterStore struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+}
+
+// NewMemoryCounterStore returns an empty in-memory CounterStore.
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{counters: make(map[string]uint64)}
+}
+
+// Set initializes or overwrites the counter of id, e.g. on enrollment.
+func (s *MemoryCounterStore) Set(id string, counter uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counters[id] = counter
+}
+
+// Load implements CounterStore.
+func (s *MemoryCounterStore) Load(id string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[id], nil
+}
+
+// CompareAndSwap implements CounterStore.
+func (s *MemoryCounterStore) CompareAndSwap(id string, old, new uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counters[id] != old {
+		return false, nil
+	}
+	s.counters[id] = new
+
+	return true, nil
+}
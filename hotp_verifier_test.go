@@ -0,0 +1,106 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestHOTPVerifier_Validate(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	store := NewMemoryCounterStore()
+	v := NewHOTPVerifier(store, nil)
+
+	tests := []struct {
+		name    string
+		code    string
+		counter uint64 // expected stored counter afterwards
+		wantErr error
+	}{
+		{"counter 0", "755224", 1, nil},
+		{"replay counter 0", "755224", 1, ErrInvalidCode},
+		{"skip to counter 3", "969429", 4, nil},
+		{"skipped counter 2 is consumed", "359152", 4, ErrInvalidCode},
+		{"beyond window", "520489", 4, ErrInvalidCode},
+		{"counter 4", "338314", 5, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Validate("token", secret, tt.code)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if c, _ := store.Load("token"); c != tt.counter {
+				t.Errorf("stored counter = %d, want %d", c, tt.counter)
+			}
+		})
+	}
+}
+
+func TestHOTPVerifier_ConcurrentSameCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	for round := 0; round < 50; round++ {
+		store := NewMemoryCounterStore()
+		v := NewHOTPVerifier(store, nil)
+
+		var (
+			wg       sync.WaitGroup
+			start    = make(chan struct{})
+			errs     = make([]error, 2)
+			accepted = make([]bool, 2)
+		)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				_, err := v.Validate("token", secret, "287082") // counter 1
+				accepted[i] = err == nil
+				errs[i] = err
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+
+		if accepted[0] == accepted[1] {
+			t.Fatalf("round %d: expected exactly one success, got errs=%v", round, errs)
+		}
+		for i, err := range errs {
+			if !accepted[i] && !errors.Is(err, ErrCodeReused) && !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("round %d: unexpected error %v", round, err)
+			}
+		}
+		if c, _ := store.Load("token"); c != 2 {
+			t.Errorf("round %d: stored counter = %d, want 2", round, c)
+		}
+	}
+}
+
+func TestHOTPVerifier_Resync(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	store := NewMemoryCounterStore()
+	store.Set("token", 0)
+	v := NewHOTPVerifier(store, nil)
+
+	codes := make([]string, 2)
+	for i := range codes {
+		code, err := GenerateHOTP(secret, uint64(40+i), nil)
+		if err != nil {
+			t.Fatalf("GenerateHOTP failed: %v", err)
+		}
+		codes[i] = code
+	}
+
+	res, err := v.Resync("token", secret, codes, 0)
+	if err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if res.Counter != 41 {
+		t.Errorf("Counter = %d, want 41", res.Counter)
+	}
+	if c, _ := store.Load("token"); c != 42 {
+		t.Errorf("stored counter = %d, want 42", c)
+	}
+}
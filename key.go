@@ -0,0 +1,211 @@
+package otp
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"time"
+)
+
+// maxStackCode is the size of the stack buffer used to render codes during validation.
+// Longer codes (custom encoders) still work, they just allocate.
+const maxStackCode = 16
+
+// Key is a secret decoded once and bound to a Param, for fast repeated generation and
+// validation of HOTP and TOTP codes.
+//
+// The package-level functions decode the base32 secret and run the HMAC key schedule on
+// every call, i.e. 2*Skew+1 times per validation. A Key does both once: each computation
+// restores the precomputed inner/outer pad state of a pooled HMAC (hash.Hash.Reset on a
+// keyed HMAC) instead. With the decimal encoder, AppendCode and the Validate methods do
+// not allocate.
+//
+// A Key is safe for concurrent use. It keeps the raw secret in memory for its lifetime.
+type Key struct {
+	param  Param
+	length int
+	pool   sync.Pool
+}
+
+// keyState is the per-goroutine scratch space of a Key.
+type keyState struct {
+	mac hash.Hash
+	msg [8]byte
+	sum []byte
+}
+
+// NewKey decodes the base32 secret and returns a Key for it.
+// If param is nil, DefaultTOTPParam is used.
+func NewKey(secret string, param *Param) (*Key, error) {
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(secretBuf, param)
+}
+
+// NewKeyFromBytes is like NewKey but takes the raw, already decoded secret.
+// The secret is copied.
+func NewKeyFromBytes(secret []byte, param *Param) (*Key, error) {
+	return newKey(append([]byte(nil), secret...), param)
+}
+
+func newKey(secret []byte, param *Param) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, ErrSecretRequired
+	}
+	if param == nil {
+		param = DefaultTOTPParam
+	}
+
+	spec, err := lookupAlgorithm(param.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !param.validDigits() {
+		return nil, ErrInvalidDigits
+	}
+	if offset, ok := param.Truncation.Offset(); ok && param.decimal() && offset >= spec.size-4 {
+		return nil, ErrInvalidTruncation
+	}
+
+	k := &Key{param: *param, length: param.codeLength()}
+	k.pool.New = func() any {
+		mac := spec.hmac.new(secret)
+		return &keyState{mac: mac, sum: make([]byte, 0, mac.Size())}
+	}
+
+	return k, nil
+}
+
+// Param returns a copy of the parameters the Key was created with.
+func (k *Key) Param() Param {
+	return k.param
+}
+
+// AppendCode appends the code for counter to dst and returns the extended buffer.
+func (k *Key) AppendCode(dst []byte, counter uint64) ([]byte, error) {
+	st := k.pool.Get().(*keyState)
+	defer k.pool.Put(st)
+
+	st.mac.Reset()
+	binary.BigEndian.PutUint64(st.msg[:], counter)
+	st.mac.Write(st.msg[:])
+	st.sum = st.mac.Sum(st.sum[:0])
+
+	if !k.param.decimal() {
+		return append(dst, k.param.Encoder.Encode(st.sum, k.param.Digits.Int())...), nil
+	}
+
+	return k.appendDecimal(dst, st.sum), nil
+}
+
+// appendDecimal is the allocation-free equivalent of deriveRFC4226Ext.
+func (k *Key) appendDecimal(dst, sum []byte) []byte {
+	digits := k.param.Digits.Int()
+
+	offset := int(sum[len(sum)-1] & maskOffset)
+	if o, ok := k.param.Truncation.Offset(); ok {
+		offset = o
+	}
+	otp := truncateAt(sum, offset, mod10[digits])
+
+	n := len(dst)
+	for i := 0; i < digits; i++ {
+		dst = append(dst, '0')
+	}
+	for i, v := len(dst)-1, otp; i >= n; i-- {
+		dst[i] = '0' + byte(v%10)
+		v /= 10
+	}
+	if k.param.Checksum {
+		dst = append(dst, byte('0'+luhnChecksum(otp, digits)))
+	}
+
+	return dst
+}
+
+// Generate returns the code for counter.
+func (k *Key) Generate(counter uint64) (string, error) {
+	var buf [maxStackCode]byte
+	code, err := k.AppendCode(buf[:0], counter)
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+// GenerateTOTP returns the code for the time step of t, like GenerateTOTP.
+// If t is zero, the current time from the Param's Clock is used.
+func (k *Key) GenerateTOTP(t time.Time) (string, error) {
+	counter, err := k.param.timeCounter(t, k.period())
+	if err != nil {
+		return "", err
+	}
+	return k.Generate(counter)
+}
+
+// Validate checks code against counter only, without a skew window.
+func (k *Key) Validate(code string, counter uint64) (bool, error) {
+	if !k.param.decimal() {
+		code = k.param.Encoder.Normalize(code)
+	}
+	if len(code) != k.length {
+		return false, ErrInvalidCodeLength
+	}
+	return k.validate(code, counter)
+}
+
+func (k *Key) validate(code string, counter uint64) (bool, error) {
+	var buf [maxStackCode]byte
+	expected, err := k.AppendCode(buf[:0], counter)
+	if err != nil {
+		return false, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(code), expected) == 1 {
+		return true, nil
+	}
+	return false, ErrInvalidCode
+}
+
+// ValidateHOTP checks code against the skew window around counter, like ValidateHOTPResult.
+func (k *Key) ValidateHOTP(code string, counter uint64) (ValidationResult, error) {
+	if k.param.Skew > 10 {
+		return ValidationResult{}, ErrInvalidSkew
+	}
+	if !k.param.decimal() {
+		code = k.param.Encoder.Normalize(code)
+	}
+	if len(code) != k.length {
+		return ValidationResult{}, ErrInvalidCode
+	}
+
+	return matchCounterWindow(counter, &k.param, func(c uint64) (bool, error) {
+		return k.validate(code, c)
+	})
+}
+
+// ValidateTOTP checks code against the skew window around the time step of t, like
+// ValidateTOTPResult. If t is zero, the current time from the Param's Clock is used.
+func (k *Key) ValidateTOTP(code string, t time.Time) (ValidationResult, error) {
+	if !k.param.decimal() {
+		code = k.param.Encoder.Normalize(code)
+	}
+	if len(code) != k.length {
+		return ValidationResult{}, ErrInvalidCode
+	}
+
+	return matchTimeWindow(t, &k.param, func(c uint64) (bool, error) {
+		return k.validate(code, c)
+	})
+}
+
+func (k *Key) period() uint {
+	if k.param.Period == 0 {
+		return 30
+	}
+	return k.param.Period
+}
@@ -0,0 +1,182 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKey_MatchesPackageFunctions(t *testing.T) {
+	params := []*Param{
+		{Digits: SixDigits, Algorithm: SHA1, Skew: 1, Period: 30},
+		{Digits: EightDigits, Algorithm: SHA256, Skew: 2, Period: 60},
+		{Digits: TenDigits, Algorithm: SHA512},
+		{Digits: SixDigits, Algorithm: SHA1, Checksum: true, Truncation: FixedTruncation(7)},
+		{Digits: SixDigits, Algorithm: SHA1, Checksum: true, Encoder: DecimalEncoder},
+		{Digits: 8, Algorithm: SHA1, Encoder: Base32Encoder},
+		DefaultSteamParam,
+	}
+
+	for _, param := range params {
+		secret, err := RandomSecret(param.Algorithm)
+		if err != nil {
+			t.Fatalf("RandomSecret failed: %v", err)
+		}
+
+		key, err := NewKey(secret, param)
+		if err != nil {
+			t.Fatalf("NewKey failed: %v", err)
+		}
+
+		for counter := uint64(0); counter < 50; counter++ {
+			want, err := GenerateHOTP(secret, counter, param)
+			if err != nil {
+				t.Fatalf("GenerateHOTP failed: %v", err)
+			}
+			got, err := key.Generate(counter)
+			if err != nil || got != want {
+				t.Fatalf("%+v counter %d: Key.Generate = %s, %v; want %s", param, counter, got, err, want)
+			}
+
+			appended, _ := key.AppendCode([]byte("x:"), counter)
+			if string(appended) != "x:"+want {
+				t.Fatalf("AppendCode = %q, want %q", appended, "x:"+want)
+			}
+
+			if ok, err := key.Validate(want, counter); !ok || err != nil {
+				t.Fatalf("Validate = %v, %v", ok, err)
+			}
+		}
+
+		now := time.Unix(1700000000, 0)
+		want, _ := GenerateTOTP(secret, now, param)
+		got, _ := key.GenerateTOTP(now)
+		if got != want {
+			t.Errorf("GenerateTOTP = %s, want %s", got, want)
+		}
+
+		wantRes, wantErr := ValidateTOTPResult(secret, want, now.Add(-time.Minute), param)
+		gotRes, gotErr := key.ValidateTOTP(want, now.Add(-time.Minute))
+		if gotRes != wantRes || !errors.Is(gotErr, wantErr) {
+			t.Errorf("ValidateTOTP = %+v, %v; want %+v, %v", gotRes, gotErr, wantRes, wantErr)
+		}
+	}
+}
+
+func TestKey_Validate(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	key, err := NewKey(secret, &Param{Digits: SixDigits, Algorithm: SHA1, Skew: 2})
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	// RFC 4226 Appendix D: counter 5 is 254676.
+	res, err := key.ValidateHOTP("254676", 4)
+	if err != nil || res.Counter != 5 || res.Offset != 1 {
+		t.Errorf("ValidateHOTP = %+v, %v", res, err)
+	}
+	if _, err := key.ValidateHOTP("254676", 8); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode outside window, got %v", err)
+	}
+	if ok, err := key.Validate("254676", 4); ok || !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Validate without window = %v, %v", ok, err)
+	}
+	if _, err := key.Validate("25467", 5); !errors.Is(err, ErrInvalidCodeLength) {
+		t.Errorf("expected ErrInvalidCodeLength, got %v", err)
+	}
+
+	if _, err := NewKey(secret, &Param{Digits: 11}); !errors.Is(err, ErrInvalidDigits) {
+		t.Errorf("expected ErrInvalidDigits, got %v", err)
+	}
+	if _, err := NewKey(secret, &Param{Digits: 12, Encoder: DecimalEncoder}); !errors.Is(err, ErrInvalidDigits) {
+		t.Errorf("explicit DecimalEncoder: expected ErrInvalidDigits, got %v", err)
+	}
+	if _, err := NewKey(secret, &Param{Digits: 6, Truncation: FixedTruncation(16)}); !errors.Is(err, ErrInvalidTruncation) {
+		t.Errorf("expected ErrInvalidTruncation, got %v", err)
+	}
+	if _, err := NewKey(secret, &Param{Digits: 6, Algorithm: 200}); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := NewKeyFromBytes(nil, nil); !errors.Is(err, ErrSecretRequired) {
+		t.Errorf("expected ErrSecretRequired, got %v", err)
+	}
+}
+
+func TestKey_ZeroAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+
+	key, err := NewKey("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", &Param{Digits: EightDigits, Algorithm: SHA1, Skew: 10, Period: 30})
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	now := time.Unix(1111111109, 0)
+	buf := make([]byte, 0, 16)
+
+	// Warm the pool and the HMAC's marshaled pad state.
+	_, _ = key.AppendCode(buf, 0)
+
+	if n := testing.AllocsPerRun(100, func() { _, _ = key.AppendCode(buf[:0], 1) }); n != 0 {
+		t.Errorf("AppendCode allocates %v times", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { _, _ = key.ValidateTOTP("07081804", now) }); n != 0 {
+		t.Errorf("ValidateTOTP allocates %v times", n)
+	}
+	if n := testing.AllocsPerRun(100, func() { _, _ = key.ValidateHOTP("00000000", 100) }); n != 0 {
+		t.Errorf("ValidateHOTP allocates %v times", n)
+	}
+}
+
+func TestKey_Concurrent(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	key, _ := NewKey(secret, &Param{Digits: SixDigits, Algorithm: SHA1})
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				c := uint64(i % len(expected))
+				if code, _ := key.Generate(c); code != expected[c] {
+					t.Errorf("counter %d: got %s, want %s", c, code, expected[c])
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkKey_ValidateTOTP(b *testing.B) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+
+	for _, skew := range []uint{0, 1, 10} {
+		param := &Param{Digits: EightDigits, Algorithm: SHA1, Period: 30, Skew: skew}
+
+		b.Run(fmt.Sprintf("ValidateTOTP/skew%d", skew), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = ValidateTOTP(secret, "00000000", now, param)
+			}
+		})
+
+		b.Run(fmt.Sprintf("Key.ValidateTOTP/skew%d", skew), func(b *testing.B) {
+			key, err := NewKey(secret, param)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = key.ValidateTOTP("00000000", now)
+			}
+		})
+	}
+}
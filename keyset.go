@@ -0,0 +1,206 @@
+package otp
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeySetEntry is one credential of a KeySet.
+type KeySetEntry struct {
+	// ID identifies the credential in matches, e.g. a token serial or user id.
+	ID string
+
+	// Secret is the base32-encoded shared secret.
+	Secret string
+
+	// Param configures the credential. If nil, DefaultTOTPParam is used.
+	Param *Param
+}
+
+// KeySet is a set of credentials that can be searched for the one that produced a code,
+// e.g. on a kiosk where users type a code but no username, or to identify a token from
+// a shared pool.
+//
+// Each secret is decoded once, into a Key, when the set is built; searches then fan out
+// over a pool of Workers goroutines. A KeySet is safe for concurrent use.
+//
+// security: searching N credentials multiplies the chance of a brute-force hit by N.
+// Combine it with a Limiter keyed by the device or client, not by credential.
+type KeySet struct {
+	// Workers is the number of goroutines used per search. Zero means GOMAXPROCS.
+	Workers int
+
+	ids  []string
+	keys []*Key
+}
+
+// KeyMatch reports a credential of a KeySet that accepted a code.
+type KeyMatch struct {
+	// Index is the position of the credential in the entries passed to NewKeySet.
+	Index int
+
+	// ID is the ID of the credential.
+	ID string
+
+	// ValidationResult reports which step of the credential's skew window matched.
+	ValidationResult
+}
+
+// KeyCode is the code generated for a credential of a KeySet.
+type KeyCode struct {
+	Index   int
+	ID      string
+	Code    string
+	Counter uint64
+}
+
+// NewKeySet decodes the secrets of entries and returns a KeySet for them. Entries with
+// an invalid secret or Param are rejected; the error names the entry's ID. Like
+// Key.ValidateHOTP, FindHOTP reports a Skew above 10 as ErrInvalidSkew.
+func NewKeySet(entries []KeySetEntry) (*KeySet, error) {
+	s := &KeySet{
+		ids:  make([]string, len(entries)),
+		keys: make([]*Key, len(entries)),
+	}
+	for i, e := range entries {
+		key, err := NewKey(e.Secret, e.Param)
+		if err != nil {
+			return nil, fmt.Errorf("credential %q: %w", e.ID, err)
+		}
+		s.ids[i] = e.ID
+		s.keys[i] = key
+	}
+	return s, nil
+}
+
+// Len returns the number of credentials in the set.
+func (s *KeySet) Len() int {
+	return len(s.keys)
+}
+
+// FindTOTP returns every credential whose skew window around t accepts code, ordered by
+// Index. If t is zero, each credential's Clock is used. It returns nil if none matched.
+// An error other than a code mismatch, such as ErrTimeBeforeT0, aborts the search and
+// names the credential.
+func (s *KeySet) FindTOTP(ctx context.Context, code string, t time.Time) ([]KeyMatch, error) {
+	return s.find(ctx, func(i int) (ValidationResult, error) {
+		return s.keys[i].ValidateTOTP(code, t)
+	})
+}
+
+// FindHOTP returns every credential whose skew window around its counter accepts code,
+// ordered by Index. counters holds the next expected counter of each credential, by
+// Index. It returns nil if none matched.
+func (s *KeySet) FindHOTP(ctx context.Context, code string, counters []uint64) ([]KeyMatch, error) {
+	if len(counters) != len(s.keys) {
+		return nil, fmt.Errorf("got %d counters for %d credentials", len(counters), len(s.keys))
+	}
+	return s.find(ctx, func(i int) (ValidationResult, error) {
+		return s.keys[i].ValidateHOTP(code, counters[i])
+	})
+}
+
+func (s *KeySet) find(ctx context.Context, validateFn func(i int) (ValidationResult, error)) ([]KeyMatch, error) {
+	var (
+		mu      sync.Mutex
+		matches []KeyMatch
+	)
+
+	err := s.each(ctx, func(i int) error {
+		res, err := validateFn(i)
+		if isCodeFailure(err) {
+			return nil // not a match
+		}
+		if err != nil {
+			return fmt.Errorf("credential %q: %w", s.ids[i], err)
+		}
+		mu.Lock()
+		matches = append(matches, KeyMatch{Index: i, ID: s.ids[i], ValidationResult: res})
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(a, b int) bool { return matches[a].Index < matches[b].Index })
+	return matches, nil
+}
+
+// GenerateTOTP returns the code of every credential for the time step of t, ordered by
+// Index, e.g. to audit a token pool. If t is zero, each credential's Clock is used.
+func (s *KeySet) GenerateTOTP(ctx context.Context, t time.Time) ([]KeyCode, error) {
+	codes := make([]KeyCode, len(s.keys))
+
+	err := s.each(ctx, func(i int) error {
+		key := s.keys[i]
+		counter, err := key.param.timeCounter(t, key.period())
+		if err != nil {
+			return fmt.Errorf("credential %q: %w", s.ids[i], err)
+		}
+		code, err := key.Generate(counter)
+		if err != nil {
+			return fmt.Errorf("credential %q: %w", s.ids[i], err)
+		}
+		codes[i] = KeyCode{Index: i, ID: s.ids[i], Code: code, Counter: counter}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// each calls fn for every credential index from a pool of workers. It stops at the
+// first error, or when ctx is done.
+func (s *KeySet) each(ctx context.Context, fn func(i int) error) error {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(s.keys) {
+		workers = len(s.keys)
+	}
+
+	var (
+		next     atomic.Int64
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		stop     atomic.Bool
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		stop.Store(true)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= len(s.keys) {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+				if err := fn(i); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,204 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKeySet(tb testing.TB, n int, param *Param) (*KeySet, []string) {
+	tb.Helper()
+
+	entries := make([]KeySetEntry, n)
+	secrets := make([]string, n)
+	for i := range entries {
+		secret, err := RandomSecret(SHA1)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		secrets[i] = secret
+		entries[i] = KeySetEntry{ID: fmt.Sprintf("token-%d", i), Secret: secret, Param: param}
+	}
+
+	set, err := NewKeySet(entries)
+	if err != nil {
+		tb.Fatalf("NewKeySet failed: %v", err)
+	}
+	return set, secrets
+}
+
+func TestKeySet_FindTOTP(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	param := &Param{Digits: EightDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+	set, secrets := newTestKeySet(t, 200, param)
+
+	for _, workers := range []int{0, 1, 7} {
+		set.Workers = workers
+		for _, idx := range []int{0, 57, 199} {
+			code, _ := GenerateTOTP(secrets[idx], now.Add(-30*time.Second), param)
+
+			matches, err := set.FindTOTP(context.Background(), code, now)
+			if err != nil {
+				t.Fatalf("FindTOTP failed: %v", err)
+			}
+			found := false
+			for _, m := range matches {
+				if m.Index == idx {
+					found = true
+					if m.ID != fmt.Sprintf("token-%d", idx) || m.Offset != -1 {
+						t.Errorf("workers %d: unexpected match %+v", workers, m)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("workers %d: credential %d not found in %+v", workers, idx, matches)
+			}
+		}
+	}
+
+	if matches, err := set.FindTOTP(context.Background(), "abc", now); matches != nil || err != nil {
+		t.Errorf("malformed code: got %+v, %v", matches, err)
+	}
+}
+
+func TestKeySet_SharedSecret(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	other, _ := RandomSecret(SHA1)
+	param := &Param{Digits: EightDigits, Period: 30, Algorithm: SHA1}
+
+	set, err := NewKeySet([]KeySetEntry{
+		{ID: "a", Secret: secret, Param: param},
+		{ID: "b", Secret: other, Param: param},
+		{ID: "c", Secret: secret, Param: param},
+	})
+	if err != nil {
+		t.Fatalf("NewKeySet failed: %v", err)
+	}
+
+	matches, err := set.FindTOTP(context.Background(), "07081804", time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatalf("FindTOTP failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].ID != "a" || matches[1].ID != "c" {
+		t.Errorf("matches = %+v, want a and c", matches)
+	}
+}
+
+func TestKeySet_FindHOTP(t *testing.T) {
+	param := &Param{Digits: SixDigits, Skew: 2, Algorithm: SHA1}
+	set, secrets := newTestKeySet(t, 50, param)
+
+	counters := make([]uint64, set.Len())
+	for i := range counters {
+		counters[i] = uint64(i * 10)
+	}
+
+	code, _ := GenerateHOTP(secrets[33], counters[33]+2, param)
+	matches, err := set.FindHOTP(context.Background(), code, counters)
+	if err != nil {
+		t.Fatalf("FindHOTP failed: %v", err)
+	}
+	found := false
+	for _, m := range matches {
+		if m.Index == 33 && m.Counter == counters[33]+2 && m.Offset == 2 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("credential 33 not found in %+v", matches)
+	}
+
+	if _, err := set.FindHOTP(context.Background(), code, counters[:10]); err == nil {
+		t.Error("expected error for mismatched counters")
+	}
+}
+
+func TestKeySet_GenerateTOTP(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	param := &Param{Digits: SixDigits, Period: 30, Algorithm: SHA1}
+	set, secrets := newTestKeySet(t, 100, param)
+
+	codes, err := set.GenerateTOTP(context.Background(), now)
+	if err != nil {
+		t.Fatalf("GenerateTOTP failed: %v", err)
+	}
+	if len(codes) != len(secrets) {
+		t.Fatalf("got %d codes, want %d", len(codes), len(secrets))
+	}
+	for i, c := range codes {
+		want, _ := GenerateTOTP(secrets[i], now, param)
+		if c.Index != i || c.Code != want || c.Counter != TimeCounterFunc(now, 30) {
+			t.Errorf("code %d = %+v, want %s", i, c, want)
+		}
+	}
+
+	late, _ := NewKeySet([]KeySetEntry{{ID: "late", Secret: secrets[0], Param: &Param{Digits: SixDigits, T0: 2000000000}}})
+	if _, err := late.GenerateTOTP(context.Background(), now); !errors.Is(err, ErrTimeBeforeT0) {
+		t.Errorf("expected ErrTimeBeforeT0, got %v", err)
+	}
+}
+
+func TestKeySet_Errors(t *testing.T) {
+	_, err := NewKeySet([]KeySetEntry{{ID: "broken", Secret: "not base32!"}})
+	if err == nil {
+		t.Fatal("expected error for invalid secret")
+	}
+	if _, err := NewKeySet([]KeySetEntry{{ID: "empty"}}); !errors.Is(err, ErrSecretRequired) {
+		t.Errorf("expected ErrSecretRequired, got %v", err)
+	}
+
+	// A Skew above 10 is only rejected where Key.ValidateHOTP rejects it.
+	wide, err := NewKeySet([]KeySetEntry{{ID: "wide", Secret: "GEZDGNBVGY3TQOJQ", Param: &Param{Digits: SixDigits, Period: 30, Skew: 11}}})
+	if err != nil {
+		t.Fatalf("NewKeySet with Skew 11: %v", err)
+	}
+	if _, err := wide.FindTOTP(context.Background(), "123456", time.Unix(1700000000, 0)); err != nil {
+		t.Errorf("FindTOTP with Skew 11: %v", err)
+	}
+	if _, err := wide.FindHOTP(context.Background(), "123456", []uint64{0}); !errors.Is(err, ErrInvalidSkew) || !strings.Contains(err.Error(), `"wide"`) {
+		t.Errorf("expected ErrInvalidSkew naming the credential, got %v", err)
+	}
+
+	// Configuration errors are reported instead of being treated as mismatches.
+	epoch, _ := NewKeySet([]KeySetEntry{{ID: "future", Secret: "GEZDGNBVGY3TQOJQ", Param: &Param{Digits: SixDigits, Period: 30, T0: 2000000000}}})
+	if _, err := epoch.FindTOTP(context.Background(), "123456", time.Unix(1700000000, 0)); !errors.Is(err, ErrTimeBeforeT0) || !strings.Contains(err.Error(), `"future"`) {
+		t.Errorf("expected ErrTimeBeforeT0 naming the credential, got %v", err)
+	}
+
+	set, _ := newTestKeySet(t, 10, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := set.FindTOTP(ctx, "123456", time.Now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	empty, _ := NewKeySet(nil)
+	if matches, err := empty.FindTOTP(context.Background(), "123456", time.Now()); matches != nil || err != nil {
+		t.Errorf("empty set: got %+v, %v", matches, err)
+	}
+}
+
+func BenchmarkKeySet_FindTOTP(b *testing.B) {
+	now := time.Unix(1700000000, 0)
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+	set, secrets := newTestKeySet(b, 1000, param)
+
+	b.Run("ValidateTOTP loop", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, secret := range secrets {
+				_, _ = ValidateTOTP(secret, "000000", now, param)
+			}
+		}
+	})
+
+	b.Run("KeySet.FindTOTP", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = set.FindTOTP(context.Background(), "000000", now)
+		}
+	})
+}
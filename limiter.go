@@ -0,0 +1,303 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LimiterConfig defines the throttling policy applied by a Limiter (RFC 4226 §7.3).
+type LimiterConfig struct {
+	// FreeAttempts is the number of consecutive failures allowed before back-off starts.
+	FreeAttempts uint
+
+	// BaseDelay is the delay imposed after the first failure beyond FreeAttempts.
+	// Each further failure doubles it.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the exponential back-off delay.
+	MaxDelay time.Duration
+
+	// MaxFailures is the number of consecutive failures after which the credential is
+	// locked until Unlock is called. Zero disables the hard lockout.
+	MaxFailures uint
+}
+
+// DefaultLimiterConfig allows 3 free attempts, then backs off from 1s up to 5m,
+// and locks the credential after 10 consecutive failures.
+var DefaultLimiterConfig = &LimiterConfig{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     5 * time.Minute,
+	MaxFailures:  10,
+}
+
+// LimiterState is the per-credential throttling state persisted by a LimiterStore.
+type LimiterState struct {
+	// Failures is the number of consecutive failed validations.
+	Failures uint `json:"failures"`
+
+	// LastFailure is the time of the most recent failed validation.
+	LastFailure time.Time `json:"last_failure"`
+
+	// Locked reports whether the credential reached MaxFailures.
+	Locked bool `json:"locked"`
+}
+
+// LimiterStore persists LimiterState per credential id.
+//
+// Implementations must be safe for concurrent use. Load must return a zero LimiterState
+// (and no error) for unknown ids. CompareAndSwap must be atomic, so that a store shared
+// by several processes counts every attempt exactly once.
+type LimiterStore interface {
+	// Load returns the state of the credential id.
+	Load(id string) (LimiterState, error)
+
+	// CompareAndSwap sets the state of id to new only if it currently equals old, as
+	// reported by LimiterState.Equal, and reports whether the swap happened. A zero old
+	// state matches an unknown id.
+	CompareAndSwap(id string, old, new LimiterState) (bool, error)
+
+	// Delete resets the state of id.
+	Delete(id string) error
+}
+
+// Equal reports whether s and o describe the same state.
+func (s LimiterState) Equal(o LimiterState) bool {
+	return s.Failures == o.Failures && s.Locked == o.Locked && s.LastFailure.Equal(o.LastFailure)
+}
+
+// ThrottleError is returned by a Limiter while a credential is in back-off.
+// It matches ErrThrottled with errors.Is.
+type ThrottleError struct {
+	// RetryAfter is the remaining time before the next attempt is allowed.
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrThrottled, e.RetryAfter)
+}
+
+func (e *ThrottleError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// maxBackoff stops the exponential back-off from overflowing when MaxDelay is unset.
+const maxBackoff = 24 * time.Hour
+
+// Limiter wraps TOTP, HOTP and OCRA validation with per-credential failure counting,
+// exponential back-off and hard lockout.
+//
+// Every attempt is counted as a failure in the store before the code is evaluated, so
+// concurrent guesses cannot all pass the throttle before any failure is recorded.
+// A rejected attempt returns ErrLocked or a *ThrottleError without evaluating the code.
+// A failed code (false, ErrInvalidCode, ErrInvalidCodeLength, ErrCodeReused) stays
+// counted; any other error, such as a malformed secret, is returned as is and its
+// attempt is withdrawn. A successful validation resets the credential state.
+type Limiter struct {
+	store LimiterStore
+	cfg   LimiterConfig
+	now   func() time.Time
+}
+
+// NewLimiter returns a Limiter using store for state and cfg as policy.
+// If store is nil, a new in-memory store is used. If cfg is nil, DefaultLimiterConfig is used.
+func NewLimiter(store LimiterStore, cfg *LimiterConfig) *Limiter {
+	if store == nil {
+		store = NewMemoryLimiterStore()
+	}
+	if cfg == nil {
+		cfg = DefaultLimiterConfig
+	}
+	return &Limiter{
+		store: store,
+		cfg:   *cfg,
+		now:   time.Now,
+	}
+}
+
+// ValidateTOTP runs ValidateTOTP for the credential id under the limiter policy.
+func (l *Limiter) ValidateTOTP(id, secret, code string, t time.Time, param *Param) (bool, error) {
+	return l.Validate(id, func() (bool, error) {
+		return ValidateTOTP(secret, code, t, param)
+	})
+}
+
+// ValidateHOTP runs ValidateHOTP for the credential id under the limiter policy.
+func (l *Limiter) ValidateHOTP(id, secret, code string, counter uint64, param *Param) (bool, error) {
+	return l.Validate(id, func() (bool, error) {
+		return ValidateHOTP(secret, code, counter, param)
+	})
+}
+
+// ValidateOCRA runs ValidateOCRA for the credential id under the limiter policy.
+func (l *Limiter) ValidateOCRA(id, secret, code string, suite Suite, input OCRAInput) (bool, error) {
+	return l.Validate(id, func() (bool, error) {
+		return ValidateOCRA(secret, code, suite, input)
+	})
+}
+
+// Validate runs an arbitrary validation fn for the credential id under the limiter policy.
+// It is useful to combine the limiter with other verifiers, e.g. a ReplayVerifier:
+//
+//	ok, err := limiter.Validate(id, func() (bool, error) {
+//	    return replay.ValidateTOTP(id, secret, code, time.Now(), param)
+//	})
+func (l *Limiter) Validate(id string, fn func() (bool, error)) (bool, error) {
+	prev, reserved, err := l.reserve(id)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := fn()
+	if ok && err == nil {
+		return true, l.store.Delete(id)
+	}
+
+	if err != nil && !isCodeFailure(err) {
+		if rerr := l.release(id, prev, reserved); rerr != nil {
+			return false, rerr
+		}
+	}
+
+	return false, err
+}
+
+// Allow reports whether an attempt for the credential id is currently permitted.
+// It returns ErrLocked if the credential is locked, or a *ThrottleError while in back-off.
+// Allow does not reserve the attempt; use Validate to evaluate codes.
+func (l *Limiter) Allow(id string) error {
+	state, err := l.store.Load(id)
+	if err != nil {
+		return err
+	}
+	return l.check(state)
+}
+
+// Unlock clears the failure count and lockout of the credential id.
+func (l *Limiter) Unlock(id string) error {
+	return l.store.Delete(id)
+}
+
+func (l *Limiter) check(state LimiterState) error {
+	if state.Locked {
+		return ErrLocked
+	}
+	if wait := state.LastFailure.Add(l.delay(state.Failures)).Sub(l.now()); wait > 0 {
+		return &ThrottleError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// reserve checks that an attempt for id is permitted and atomically records it as a
+// failure. It returns the state before and after the reservation.
+func (l *Limiter) reserve(id string) (prev, reserved LimiterState, err error) {
+	for {
+		prev, err = l.store.Load(id)
+		if err != nil {
+			return prev, reserved, err
+		}
+		if err := l.check(prev); err != nil {
+			return prev, reserved, err
+		}
+
+		reserved = prev
+		reserved.Failures++
+		reserved.LastFailure = l.now()
+		reserved.Locked = l.cfg.MaxFailures > 0 && reserved.Failures >= l.cfg.MaxFailures
+
+		swapped, err := l.store.CompareAndSwap(id, prev, reserved)
+		if err != nil || swapped {
+			return prev, reserved, err
+		}
+	}
+}
+
+// release withdraws an attempt reserved by reserve. If no other attempt was recorded
+// since, the previous state is restored; otherwise only the failure count is decreased.
+func (l *Limiter) release(id string, prev, reserved LimiterState) error {
+	for {
+		cur, err := l.store.Load(id)
+		if err != nil {
+			return err
+		}
+		if cur.Failures == 0 {
+			return nil // reset by a success or Unlock in the meantime
+		}
+
+		next := prev
+		if !cur.Equal(reserved) {
+			next = cur
+			next.Failures--
+			next.Locked = l.cfg.MaxFailures > 0 && next.Failures >= l.cfg.MaxFailures
+		}
+
+		swapped, err := l.store.CompareAndSwap(id, cur, next)
+		if err != nil || swapped {
+			return err
+		}
+	}
+}
+
+// delay returns the back-off imposed after the given number of consecutive failures.
+func (l *Limiter) delay(failures uint) time.Duration {
+	if failures <= l.cfg.FreeAttempts || l.cfg.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := l.cfg.BaseDelay
+	for i := l.cfg.FreeAttempts + 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if l.cfg.MaxDelay > 0 && d > l.cfg.MaxDelay {
+		return l.cfg.MaxDelay
+	}
+
+	return d
+}
+
+func isCodeFailure(err error) bool {
+	return errors.Is(err, ErrInvalidCode) ||
+		errors.Is(err, ErrInvalidCodeLength) ||
+		errors.Is(err, ErrCodeReused)
+}
+
+// MemoryLimiterStore is an in-memory LimiterStore, suitable for a single process.
+type MemoryLimiterStore struct {
+	mu     sync.Mutex
+	states map[string]LimiterState
+}
+
+// NewMemoryLimiterStore returns an empty in-memory LimiterStore.
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{states: make(map[string]LimiterState)}
+}
+
+// Load implements LimiterStore.
+func (s *MemoryLimiterStore) Load(id string) (LimiterState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[id], nil
+}
+
+// CompareAndSwap implements LimiterStore.
+func (s *MemoryLimiterStore) CompareAndSwap(id string, old, new LimiterState) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.states[id].Equal(old) {
+		return false, nil
+	}
+	s.states[id] = new
+
+	return true, nil
+}
+
+// Delete implements LimiterStore.
+func (s *MemoryLimiterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, id)
+	return nil
+}
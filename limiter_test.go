@@ -0,0 +1,158 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter_BackoffAndLockout(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1000, 0)
+
+	l := NewLimiter(nil, &LimiterConfig{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		MaxFailures:  6,
+	})
+	l.now = func() time.Time { return now }
+
+	fail := func() error {
+		_, err := l.ValidateHOTP("alice", secret, "000000", 0, nil)
+		return err
+	}
+
+	// Free attempts.
+	for i := 0; i < 2; i++ {
+		if err := fail(); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+
+	// Third failure starts back-off of 1s, then 2s, then 4s (capped).
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if err := fail(); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("failure %d: expected ErrInvalidCode, got %v", i+3, err)
+		}
+
+		err := fail()
+		var te *ThrottleError
+		if !errors.As(err, &te) || !errors.Is(err, ErrThrottled) {
+			t.Fatalf("expected ThrottleError, got %v", err)
+		}
+		if te.RetryAfter != want {
+			t.Errorf("RetryAfter = %v, want %v", te.RetryAfter, want)
+		}
+
+		now = now.Add(want)
+	}
+
+	// Sixth failure locks the credential.
+	if err := fail(); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := l.ValidateHOTP("alice", secret, "755224", 0, nil); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	// Other credentials are unaffected.
+	if ok, err := l.ValidateHOTP("bob", secret, "755224", 0, nil); !ok || err != nil {
+		t.Errorf("expected bob to validate, got ok=%v err=%v", ok, err)
+	}
+
+	if err := l.Unlock("alice"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if ok, err := l.ValidateHOTP("alice", secret, "755224", 0, nil); !ok || err != nil {
+		t.Errorf("expected alice to validate after unlock, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLimiter_SuccessResets(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	store := NewMemoryLimiterStore()
+	l := NewLimiter(store, nil)
+
+	for i := 0; i < 3; i++ {
+		_, _ = l.ValidateHOTP("alice", secret, "000000", 0, nil)
+	}
+	if st, _ := store.Load("alice"); st.Failures != 3 {
+		t.Fatalf("Failures = %d, want 3", st.Failures)
+	}
+
+	if ok, err := l.ValidateHOTP("alice", secret, "755224", 0, nil); !ok || err != nil {
+		t.Fatalf("expected success, got ok=%v err=%v", ok, err)
+	}
+	if st, _ := store.Load("alice"); st.Failures != 0 {
+		t.Errorf("Failures = %d after success, want 0", st.Failures)
+	}
+}
+
+func TestLimiter_NonCodeErrorNotCounted(t *testing.T) {
+	store := NewMemoryLimiterStore()
+	l := NewLimiter(store, nil)
+
+	if _, err := l.ValidateTOTP("alice", "!!INVALID_BASE32!!", "000000", time.Now(), nil); err == nil {
+		t.Fatal("expected error for invalid secret")
+	}
+	if st, _ := store.Load("alice"); st.Failures != 0 {
+		t.Errorf("Failures = %d, want 0", st.Failures)
+	}
+}
+
+func TestLimiter_OCRA(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	suite := MustRawSuite("OCRA-1:HOTP-SHA1-6:QN08")
+	input := OCRAInput{Challenge: []byte("00000000")}
+
+	code, err := GenerateOCRA(secret, suite, input)
+	if err != nil {
+		t.Fatalf("GenerateOCRA failed: %v", err)
+	}
+
+	l := NewLimiter(nil, nil)
+	if ok, err := l.ValidateOCRA("alice", secret, code, suite, input); !ok || err != nil {
+		t.Errorf("expected success, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestLimiter_ConcurrentGuesses(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	store := NewMemoryLimiterStore()
+	l := NewLimiter(store, &LimiterConfig{
+		FreeAttempts: 1,
+		BaseDelay:    time.Minute,
+		MaxFailures:  3,
+	})
+
+	var (
+		wg        sync.WaitGroup
+		start     = make(chan struct{})
+		evaluated atomic.Int32
+	)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, _ = l.Validate("alice", func() (bool, error) {
+				evaluated.Add(1)
+				return ValidateHOTP(secret, "000000", 0, nil)
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// The first failure is free, the second starts the back-off.
+	if n := evaluated.Load(); n != 2 {
+		t.Errorf("%d guesses evaluated, want 2", n)
+	}
+	if st, _ := store.Load("alice"); st.Failures != 2 {
+		t.Errorf("Failures = %d, want 2", st.Failures)
+	}
+}
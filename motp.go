@@ -0,0 +1,94 @@
+package otp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMOTPParam provides the Mobile-OTP configuration: 6 hex characters, a 10-second
+// period and 3 steps of skew. The original mOTP verifier accepted ±3 minutes, which is
+// Skew 18; raise Skew for devices with poor clocks.
+var DefaultMOTPParam = &Param{
+	Digits: SixDigits,
+	Period: 10,
+	Skew:   3,
+}
+
+// GenerateMOTP generates a Mobile-OTP (mOTP) code: the first Digits lower-case hex
+// characters of MD5(epoch/Period || secret || pin), where epoch/Period is written in decimal.
+//
+// Unlike the other generators, secret is used verbatim (it is typically the 16 hex
+// characters of the mOTP init-secret) and is not base32-decoded. Algorithm and Encoder
+// in param are ignored. If param is nil, DefaultMOTPParam is used.
+func GenerateMOTP(secret, pin string, t time.Time, param *Param) (string, error) {
+	param = motpParam(param)
+	if secret == "" {
+		return "", ErrSecretRequired
+	}
+	if param.Digits.Int() < 1 || param.Digits.Int() > md5.Size*2 {
+		return "", ErrInvalidDigits
+	}
+
+	counter, err := param.timeCounter(t, param.Period)
+	if err != nil {
+		return "", err
+	}
+
+	return deriveMOTP(secret, pin, counter, param.Digits.Int()), nil
+}
+
+// ValidateMOTP checks whether the given mOTP code is valid for the specified time, secret
+// and PIN, accepting Skew steps on either side. The comparison is case-insensitive and
+// constant-time. If param is nil, DefaultMOTPParam is used.
+func ValidateMOTP(secret, pin, code string, t time.Time, param *Param) (bool, error) {
+	if _, err := ValidateMOTPResult(secret, pin, code, t, param); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidateMOTPResult is like ValidateMOTP but reports which time step of the skew window
+// matched, as ValidateTOTPResult does.
+func ValidateMOTPResult(secret, pin, code string, t time.Time, param *Param) (ValidationResult, error) {
+	param = motpParam(param)
+	if secret == "" {
+		return ValidationResult{}, ErrSecretRequired
+	}
+	digits := param.Digits.Int()
+	if digits < 1 || digits > md5.Size*2 {
+		return ValidationResult{}, ErrInvalidDigits
+	}
+
+	code = strings.ToLower(code)
+	return matchTimeWindow(t, param, func(counter uint64) (bool, error) {
+		return validate(code, digits, func() (string, error) {
+			return deriveMOTP(secret, pin, counter, digits), nil
+		})
+	})
+}
+
+func deriveMOTP(secret, pin string, counter uint64, digits int) string {
+	h := md5.New()
+	h.Write([]byte(strconv.FormatUint(counter, 10)))
+	h.Write([]byte(secret))
+	h.Write([]byte(pin))
+	return hex.EncodeToString(h.Sum(nil))[:digits]
+}
+
+// motpParam returns a copy of param with the mOTP period defaulted.
+func motpParam(param *Param) *Param {
+	if param == nil {
+		param = DefaultMOTPParam
+	}
+	p := *param
+	if p.Period == 0 {
+		p.Period = DefaultMOTPParam.Period
+	}
+	if p.Digits == 0 {
+		p.Digits = DefaultMOTPParam.Digits
+	}
+	return &p
+}
@@ -0,0 +1,79 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGenerateMOTP(t *testing.T) {
+	tests := []struct {
+		secret string
+		pin    string
+		unix   int64
+		want   string
+	}{
+		{"1234567890abcdef", "1234", 1700000000, "660af9"},
+		{"1234567890abcdef", "1234", 1700000009, "660af9"},
+		{"1234567890abcdef", "1234", 1700000010, "a42821"},
+		{"fedcba0987654321", "0000", 1700000000, "91cc28"},
+	}
+
+	for _, tt := range tests {
+		got, err := GenerateMOTP(tt.secret, tt.pin, time.Unix(tt.unix, 0), nil)
+		if err != nil {
+			t.Fatalf("GenerateMOTP failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateMOTP(%s, %s, %d) = %s, want %s", tt.secret, tt.pin, tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := GenerateMOTP("", "1234", time.Now(), nil); !errors.Is(err, ErrSecretRequired) {
+		t.Errorf("expected ErrSecretRequired, got %v", err)
+	}
+	if _, err := GenerateMOTP("1234567890abcdef", "1234", time.Now(), &Param{Digits: 33}); !errors.Is(err, ErrInvalidDigits) {
+		t.Errorf("expected ErrInvalidDigits, got %v", err)
+	}
+}
+
+func TestValidateMOTP(t *testing.T) {
+	const secret, pin = "1234567890abcdef", "1234"
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		code       string
+		pin        string
+		at         time.Time
+		param      *Param
+		wantOffset int64
+		wantErr    error
+	}{
+		{name: "current step", code: "660af9", pin: pin, at: now},
+		{name: "upper case", code: "660AF9", pin: pin, at: now},
+		{name: "client behind", code: "660af9", pin: pin, at: now.Add(30 * time.Second), wantOffset: -3},
+		{name: "client ahead", code: "a42821", pin: pin, at: now.Add(-10 * time.Second), wantOffset: 2},
+		{name: "outside window", code: "660af9", pin: pin, at: now.Add(40 * time.Second), wantErr: ErrInvalidCode},
+		{name: "no skew", code: "a42821", pin: pin, at: now, param: &Param{Period: 10}, wantErr: ErrInvalidCode},
+		{name: "wrong pin", code: "660af9", pin: "4321", at: now, wantErr: ErrInvalidCode},
+		{name: "wrong length", code: "660af", pin: pin, at: now, wantErr: ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ValidateMOTPResult(secret, tt.pin, tt.code, tt.at, tt.param)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && res.Offset != tt.wantOffset {
+				t.Errorf("Offset = %d, want %d", res.Offset, tt.wantOffset)
+			}
+		})
+	}
+
+	ok, err := ValidateMOTP(secret, pin, "660af9", now, nil)
+	if !ok || err != nil {
+		t.Errorf("ValidateMOTP = %v, %v", ok, err)
+	}
+}
@@ -1,5 +1,10 @@
 package otp
 
+import (
+	"fmt"
+	"time"
+)
+
 // GenerateOCRA generates a one-time password (OTP) using the OCRA algorithm (RFC 6287)
 // for the given secret, suite, and input parameters.
 //
@@ -35,3 +40,24 @@ func ValidateOCRA(secret, code string, suite Suite, input OCRAInput) (bool, erro
 
 	return validateRFC6287(code, secretBuf, suite, input)
 }
+
+// OCRATimestamp returns the OCRAInput.Timestamp for suite at time t: the number of
+// TimeStep intervals since param.T0 (the Unix epoch by default), as 8 big-endian bytes.
+// If t is zero, the current time from param.Clock is used. param may be nil; only its
+// T0 and Clock are used.
+func OCRATimestamp(suite Suite, t time.Time, param *Param) ([]byte, error) {
+	cfg := suite.Config()
+	if !cfg.IncludeTimestamp || cfg.TimeStep <= 0 {
+		return nil, fmt.Errorf("suite %q has no timestamp input", cfg.Raw)
+	}
+	if param == nil {
+		param = &Param{}
+	}
+
+	counter, err := param.timeCounter(t, uint(cfg.TimeStep))
+	if err != nil {
+		return nil, err
+	}
+
+	return To8ByteBigEndian(counter), nil
+}
@@ -1,6 +1,10 @@
 package otp
 
-import "testing"
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
 
 func TestGenerateAndValidateOCRA(t *testing.T) {
 	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
@@ -187,3 +191,35 @@ func TestOCRAInputValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestOCRATimestamp(t *testing.T) {
+	suite, err := NewRawSuite("OCRA-1:HOTP-SHA512-8:QN08-T1M")
+	if err != nil {
+		t.Fatalf("NewRawSuite failed: %v", err)
+	}
+
+	// RFC 6287 Appendix C: "Mar 25 2008, 12:06:30 GMT" is T = 132d0b6.
+	at := time.Date(2008, 3, 25, 12, 6, 30, 0, time.UTC)
+
+	ts, err := OCRATimestamp(suite, at, nil)
+	if err != nil {
+		t.Fatalf("OCRATimestamp failed: %v", err)
+	}
+	if got := hex.EncodeToString(ts); got != "000000000132d0b6" {
+		t.Errorf("timestamp = %s, want 000000000132d0b6", got)
+	}
+
+	ts, _ = OCRATimestamp(suite, time.Time{}, &Param{Clock: func() time.Time { return at }})
+	if got := hex.EncodeToString(ts); got != "000000000132d0b6" {
+		t.Errorf("timestamp from clock = %s, want 000000000132d0b6", got)
+	}
+
+	ts, _ = OCRATimestamp(suite, at, &Param{T0: at.Unix() - 120})
+	if got := hex.EncodeToString(ts); got != "0000000000000002" {
+		t.Errorf("timestamp with T0 = %s, want 0000000000000002", got)
+	}
+
+	if _, err := OCRATimestamp(MustRawSuite("OCRA-1:HOTP-SHA1-6:QN08"), at, nil); err == nil {
+		t.Error("expected error for suite without timestamp")
+	}
+}
@@ -0,0 +1,370 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOOBConfig issues 6-digit codes valid for 10 minutes, with 5 attempts each.
+var DefaultOOBConfig = &OOBConfig{
+	Digits:      SixDigits,
+	TTL:         10 * time.Minute,
+	MaxAttempts: 5,
+}
+
+// OOBConfig configures an OOBIssuer.
+type OOBConfig struct {
+	// Digits is the length of the numeric codes. Zero means 6.
+	Digits Digits
+
+	// TTL is how long a code stays valid. Zero means 10 minutes.
+	TTL time.Duration
+
+	// MaxAttempts is the number of wrong guesses after which a code is locked.
+	// Zero means 5.
+	MaxAttempts int
+
+	// Issuer names the service in message subjects and bodies, e.g. "Example".
+	Issuer string
+
+	// Domain, if set, appends a WebOTP origin-bound line (`@Domain #code`) to message
+	// bodies, so that browsers can autofill codes received by SMS.
+	Domain string
+
+	// HashKey is the server-side secret keying the HMAC under which codes are stored, so
+	// that reading the OOBStore does not reveal pending codes. Keep it out of the store,
+	// e.g. in a Keyring or the environment; 32 random bytes are enough.
+	//
+	// If empty, NewOOBIssuer generates a random key, and codes can only be verified by
+	// the OOBIssuer that issued them. Set it when the store is shared by several
+	// processes or outlives the process.
+	HashKey []byte
+}
+
+func (c *OOBConfig) digits() int {
+	if c.Digits == 0 {
+		return DefaultOOBConfig.Digits.Int()
+	}
+	return c.Digits.Int()
+}
+
+func (c *OOBConfig) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return DefaultOOBConfig.TTL
+	}
+	return c.TTL
+}
+
+func (c *OOBConfig) maxAttempts() int {
+	if c.MaxAttempts <= 0 {
+		return DefaultOOBConfig.MaxAttempts
+	}
+	return c.MaxAttempts
+}
+
+// OOBMessage is an out-of-band code ready to be delivered by a Sender.
+type OOBMessage struct {
+	// To is the recipient address or phone number, as passed to Issue.
+	To string
+
+	// Purpose is the action the code authorizes, as passed to Issue.
+	Purpose string
+
+	// Code is the one-time code, also contained in Body.
+	Code string
+
+	// Expires is the time at which the code stops being accepted.
+	Expires time.Time
+
+	// Subject is a subject line for channels that have one, such as email.
+	Subject string
+
+	// Body is the text of the message.
+	Body string
+}
+
+// Sender delivers out-of-band codes, e.g. by email or SMS.
+type Sender interface {
+	Send(ctx context.Context, msg OOBMessage) error
+}
+
+// FormatWebOTP appends the WebOTP origin-bound code line to an SMS body:
+//
+//	Your code is 123456.
+//
+//	@example.com #123456
+//
+// Browsers implementing the WebOTP API only autofill the code on pages of domain.
+func FormatWebOTP(body, domain, code string) string {
+	return body + "\n\n@" + domain + " #" + code
+}
+
+// OOBCode is a pending out-of-band code, as held by an OOBStore.
+// The code itself is not stored, only a salted HMAC of it keyed with OOBConfig.HashKey;
+// without the key, the few million possible codes cannot be tried against it.
+type OOBCode struct {
+	// Hash is the salted HMAC of the code.
+	Hash string
+
+	// Expires is the time at which the code stops being accepted.
+	Expires time.Time
+
+	// Attempts is the number of failed verifications so far.
+	Attempts int
+}
+
+// IsZero reports whether c holds no pending code.
+func (c OOBCode) IsZero() bool {
+	return c.Hash == ""
+}
+
+// OOBStore persists the pending out-of-band code of each (id, purpose) pair.
+//
+// Implementations must be safe for concurrent use. CompareAndSwap must be atomic,
+// e.g. an `UPDATE ... WHERE id = ? AND purpose = ? AND hash = ? AND attempts = ?`.
+type OOBStore interface {
+	// Save stores code for (id, purpose), replacing any pending one.
+	Save(id, purpose string, code OOBCode) error
+
+	// Load returns the pending code of (id, purpose), or a zero OOBCode if there is none.
+	Load(id, purpose string) (OOBCode, error)
+
+	// CompareAndSwap replaces the pending code of (id, purpose) with new only if its Hash
+	// and Attempts equal those of old, and reports whether it did. A zero new deletes it.
+	CompareAndSwap(id, purpose string, old, new OOBCode) (bool, error)
+}
+
+// OOBIssuer issues random numeric one-time codes delivered out of band, e.g. by email or
+// SMS, and verifies them.
+//
+// Each code is bound to an id (typically the user) and a purpose, such as "login" or
+// "reset-password": it is only accepted for the same pair, before it expires, within
+// MaxAttempts guesses, and only once. Issuing a new code for a pair replaces the pending
+// one. Throttle Issue with a Limiter to bound the number of messages sent.
+type OOBIssuer struct {
+	store  OOBStore
+	sender Sender
+	cfg    OOBConfig
+	now    func() time.Time
+}
+
+// NewOOBIssuer returns an OOBIssuer delivering codes with sender and keeping them in store.
+// If store is nil, a new in-memory store is used. If cfg is nil, DefaultOOBConfig is used.
+func NewOOBIssuer(store OOBStore, sender Sender, cfg *OOBConfig) *OOBIssuer {
+	if store == nil {
+		store = NewMemoryOOBStore()
+	}
+	if cfg == nil {
+		cfg = DefaultOOBConfig
+	}
+
+	i := &OOBIssuer{store: store, sender: sender, cfg: *cfg, now: time.Now}
+	if len(i.cfg.HashKey) == 0 {
+		i.cfg.HashKey = make([]byte, sha256.Size)
+		_, _ = rand.Read(i.cfg.HashKey) // never fails, see crypto/rand.Read
+	}
+	return i
+}
+
+// Issue generates a code for (id, purpose), stores it and sends it to the recipient to.
+// It returns the sent message. If sending fails, the code is withdrawn.
+func (i *OOBIssuer) Issue(ctx context.Context, id, to, purpose string) (OOBMessage, error) {
+	if i.sender == nil {
+		return OOBMessage{}, fmt.Errorf("no sender configured")
+	}
+
+	digits := i.cfg.digits()
+	if digits < 1 || digits >= len(mod10) {
+		return OOBMessage{}, ErrInvalidDigits
+	}
+	code, err := randomString("0123456789", digits)
+	if err != nil {
+		return OOBMessage{}, err
+	}
+	hash, err := hashOOBCode(i.cfg.HashKey, code)
+	if err != nil {
+		return OOBMessage{}, err
+	}
+
+	expires := i.now().Add(i.cfg.ttl())
+	pending := OOBCode{Hash: hash, Expires: expires}
+	if err := i.store.Save(id, purpose, pending); err != nil {
+		return OOBMessage{}, err
+	}
+
+	msg := i.message(to, purpose, code, expires)
+	if err := i.sender.Send(ctx, msg); err != nil {
+		_, _ = i.store.CompareAndSwap(id, purpose, pending, OOBCode{})
+		return OOBMessage{}, err
+	}
+
+	return msg, nil
+}
+
+func (i *OOBIssuer) message(to, purpose, code string, expires time.Time) OOBMessage {
+	name := "verification"
+	if i.cfg.Issuer != "" {
+		name = i.cfg.Issuer + " " + name
+	}
+	subject := fmt.Sprintf("Your %s code", name)
+	body := fmt.Sprintf("Your %s code for %s is %s. It expires in %s.", name, purpose, code, formatTTL(i.cfg.ttl()))
+	if i.cfg.Domain != "" {
+		body = FormatWebOTP(body, i.cfg.Domain, code)
+	}
+
+	return OOBMessage{
+		To:      to,
+		Purpose: purpose,
+		Code:    code,
+		Expires: expires,
+		Subject: subject,
+		Body:    body,
+	}
+}
+
+// formatTTL renders d in whole minutes, or seconds below a minute.
+func formatTTL(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d seconds", int(d/time.Second))
+	}
+	if m := int(d / time.Minute); m != 1 {
+		return fmt.Sprintf("%d minutes", m)
+	}
+	return "1 minute"
+}
+
+// Verify checks code against the pending code of (id, purpose) and consumes it on success.
+//
+// It fails with ErrInvalidCode if there is no pending code or code does not match,
+// ErrCodeExpired once the code has expired, ErrLocked after MaxAttempts failed attempts,
+// and ErrCodeReused if a concurrent call consumed the code first.
+func (i *OOBIssuer) Verify(id, purpose, code string) error {
+	matchedBefore := false
+	for {
+		pending, err := i.store.Load(id, purpose)
+		if err != nil {
+			return err
+		}
+		if pending.IsZero() {
+			if matchedBefore {
+				return ErrCodeReused
+			}
+			return ErrInvalidCode
+		}
+
+		if !i.now().Before(pending.Expires) {
+			_, _ = i.store.CompareAndSwap(id, purpose, pending, OOBCode{})
+			return ErrCodeExpired
+		}
+		if pending.Attempts >= i.cfg.maxAttempts() {
+			return ErrLocked
+		}
+
+		next := OOBCode{}
+		if !verifyOOBCode(i.cfg.HashKey, code, pending.Hash) {
+			next = pending
+			next.Attempts++
+		} else {
+			matchedBefore = true
+		}
+
+		swapped, err := i.store.CompareAndSwap(id, purpose, pending, next)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			if next.IsZero() {
+				return nil
+			}
+			return ErrInvalidCode
+		}
+	}
+}
+
+// oobSaltSize is the size of the random salt of OOBCode hashes.
+const oobSaltSize = 16
+
+// hashOOBCode returns base64(salt || HMAC-SHA256(key, salt || code)) for a fresh random salt.
+func hashOOBCode(key []byte, code string) (string, error) {
+	buf := make([]byte, oobSaltSize, oobSaltSize+sha256.Size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write(buf)
+	h.Write([]byte(code))
+
+	return base64.RawStdEncoding.EncodeToString(h.Sum(buf)), nil
+}
+
+func verifyOOBCode(key []byte, code, hash string) bool {
+	raw, err := base64.RawStdEncoding.DecodeString(hash)
+	if err != nil || len(raw) != oobSaltSize+sha256.Size {
+		return false
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write(raw[:oobSaltSize])
+	h.Write([]byte(strings.TrimSpace(code)))
+
+	return hmac.Equal(h.Sum(nil), raw[oobSaltSize:])
+}
+
+type oobKey struct {
+	id      string
+	purpose string
+}
+
+// MemoryOOBStore is an in-memory OOBStore.
+type MemoryOOBStore struct {
+	mu    sync.Mutex
+	codes map[oobKey]OOBCode
+}
+
+// NewMemoryOOBStore returns an empty in-memory OOBStore.
+func NewMemoryOOBStore() *MemoryOOBStore {
+	return &MemoryOOBStore{codes: make(map[oobKey]OOBCode)}
+}
+
+// Save implements OOBStore.
+func (s *MemoryOOBStore) Save(id, purpose string, code OOBCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[oobKey{id, purpose}] = code
+	return nil
+}
+
+// Load implements OOBStore.
+func (s *MemoryOOBStore) Load(id, purpose string) (OOBCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[oobKey{id, purpose}], nil
+}
+
+// CompareAndSwap implements OOBStore.
+func (s *MemoryOOBStore) CompareAndSwap(id, purpose string, old, new OOBCode) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := oobKey{id, purpose}
+	cur := s.codes[key]
+	if cur.Hash != old.Hash || cur.Attempts != old.Attempts {
+		return false, nil
+	}
+
+	if new.IsZero() {
+		delete(s.codes, key)
+	} else {
+		s.codes[key] = new
+	}
+
+	return true, nil
+}
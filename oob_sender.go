@@ -0,0 +1,191 @@
+package otp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WriterSender is a Sender that writes messages to an io.Writer, for local development
+// and tests, e.g. NewWriterSender(os.Stdout).
+type WriterSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSender returns a WriterSender writing to w.
+func NewWriterSender(w io.Writer) *WriterSender {
+	return &WriterSender{w: w}
+}
+
+// Send implements Sender.
+func (s *WriterSender) Send(_ context.Context, msg OOBMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeOOBMessage(s.w, msg)
+}
+
+// FileSender is a Sender that appends messages to a file, for local development.
+type FileSender struct {
+	// Path is the file to append to. It is created with mode 0600 if needed.
+	Path string
+
+	mu sync.Mutex
+}
+
+// Send implements Sender.
+func (s *FileSender) Send(_ context.Context, msg OOBMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := writeOOBMessage(f, msg); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeOOBMessage(w io.Writer, msg OOBMessage) error {
+	_, err := fmt.Fprintf(w, "To: %s\nSubject: %s\nExpires: %s\n\n%s\n\n",
+		msg.To, msg.Subject, msg.Expires.Format(time.RFC3339), msg.Body)
+	return err
+}
+
+// SMTPSender is a Sender that emails messages as plain text through an SMTP server.
+// The recipient is the message's To address.
+type SMTPSender struct {
+	// Addr is the server address, e.g. "smtp.example.com:587".
+	Addr string
+
+	// From is the sender address, e.g. "Example <no-reply@example.com>".
+	From string
+
+	// Auth authenticates with the server; nil means no authentication. Send fails if
+	// Auth is set and the server does not offer AUTH.
+	Auth smtp.Auth
+
+	// TLSConfig is used for STARTTLS, which is used whenever the server offers it.
+	// Nil means a default configuration for the host of Addr.
+	TLSConfig *tls.Config
+
+	// AllowPlaintext lets Send deliver messages in plaintext to servers that do not offer
+	// STARTTLS. By default, Send fails before sending anything to such servers.
+	AllowPlaintext bool
+}
+
+// Send implements Sender. The deadline of ctx, if any, bounds the whole SMTP exchange.
+func (s *SMTPSender) Send(ctx context.Context, msg OOBMessage) error {
+	from, err := envelopeAddress(s.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := envelopeAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid subject")
+	}
+
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		cfg := s.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: host}
+		}
+		if err := c.StartTLS(cfg); err != nil {
+			return err
+		}
+	} else if !s.AllowPlaintext {
+		return fmt.Errorf("smtp server %s does not support STARTTLS", s.Addr)
+	}
+	if s.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp server %s does not support AUTH", s.Addr)
+		}
+		if err := c.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(smtpMessage(s.From, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// envelopeAddress returns the bare address of a header address such as
+// "Example <no-reply@example.com>", rejecting header injection.
+func envelopeAddress(addr string) (string, error) {
+	if addr == "" || strings.ContainsAny(addr, "\r\n") {
+		return "", fmt.Errorf("%q", addr)
+	}
+	if i := strings.LastIndexByte(addr, '<'); i >= 0 && strings.HasSuffix(addr, ">") {
+		addr = addr[i+1 : len(addr)-1]
+	}
+	if !strings.Contains(addr, "@") || strings.ContainsAny(addr, " <>") {
+		return "", fmt.Errorf("%q", addr)
+	}
+	return addr, nil
+}
+
+// smtpMessage renders msg as an RFC 5322 plain-text email.
+func smtpMessage(from string, msg OOBMessage) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + msg.To + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
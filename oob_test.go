@@ -0,0 +1,365 @@
+package otp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureSender records sent messages.
+type captureSender struct {
+	mu   sync.Mutex
+	msgs []OOBMessage
+	err  error
+}
+
+func (s *captureSender) Send(_ context.Context, msg OOBMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func TestOOBIssuer(t *testing.T) {
+	sender := &captureSender{}
+	issuer := NewOOBIssuer(nil, sender, &OOBConfig{Issuer: "Example", Domain: "example.com"})
+	now := time.Unix(1700000000, 0)
+	issuer.now = func() time.Time { return now }
+
+	msg, err := issuer.Issue(context.Background(), "alice", "+15550100", "login")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if len(sender.msgs) != 1 || sender.msgs[0] != msg {
+		t.Fatalf("sent %+v, want %+v", sender.msgs, msg)
+	}
+	if !regexp.MustCompile(`^[0-9]{6}$`).MatchString(msg.Code) {
+		t.Errorf("code %q is not 6 digits", msg.Code)
+	}
+	if !msg.Expires.Equal(now.Add(10 * time.Minute)) {
+		t.Errorf("Expires = %v", msg.Expires)
+	}
+	wantBody := "Your Example verification code for login is " + msg.Code + ". It expires in 10 minutes.\n\n@example.com #" + msg.Code
+	if msg.Body != wantBody || msg.Subject != "Your Example verification code" {
+		t.Errorf("unexpected message %q / %q", msg.Subject, msg.Body)
+	}
+
+	// Bound to id and purpose.
+	if err := issuer.Verify("alice", "reset-password", msg.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("other purpose: expected ErrInvalidCode, got %v", err)
+	}
+	if err := issuer.Verify("bob", "login", msg.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("other id: expected ErrInvalidCode, got %v", err)
+	}
+
+	if err := issuer.Verify("alice", "login", msg.Code); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := issuer.Verify("alice", "login", msg.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("second use: expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestOOBIssuer_ExpiryAndAttempts(t *testing.T) {
+	sender := &captureSender{}
+	issuer := NewOOBIssuer(nil, sender, &OOBConfig{Digits: EightDigits, TTL: time.Minute, MaxAttempts: 3})
+	now := time.Unix(1700000000, 0)
+	issuer.now = func() time.Time { return now }
+
+	msg, err := issuer.Issue(context.Background(), "alice", "alice@example.com", "login")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if len(msg.Code) != 8 {
+		t.Errorf("code %q is not 8 digits", msg.Code)
+	}
+
+	wrong := "00000000"
+	if wrong == msg.Code {
+		wrong = "11111111"
+	}
+	for i := 0; i < 3; i++ {
+		if err := issuer.Verify("alice", "login", wrong); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i, err)
+		}
+	}
+	if err := issuer.Verify("alice", "login", msg.Code); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+
+	// A new code resets the attempts.
+	msg, _ = issuer.Issue(context.Background(), "alice", "alice@example.com", "login")
+	now = now.Add(time.Minute)
+	if err := issuer.Verify("alice", "login", msg.Code); !errors.Is(err, ErrCodeExpired) {
+		t.Errorf("expected ErrCodeExpired, got %v", err)
+	}
+	if err := issuer.Verify("alice", "login", msg.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expired code must be removed, got %v", err)
+	}
+
+	// A failed send withdraws the code.
+	sender.err = errors.New("gateway down")
+	if _, err := issuer.Issue(context.Background(), "alice", "alice@example.com", "login"); err == nil {
+		t.Fatal("expected send error")
+	}
+	if pending, _ := issuer.store.Load("alice", "login"); !pending.IsZero() {
+		t.Errorf("code not withdrawn after failed send: %+v", pending)
+	}
+}
+
+func TestOOBIssuer_Concurrent(t *testing.T) {
+	issuer := NewOOBIssuer(nil, &captureSender{}, nil)
+	msg, err := issuer.Issue(context.Background(), "alice", "alice@example.com", "login")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := issuer.Verify("alice", "login", msg.Code)
+			if err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrCodeReused) && !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("code accepted %d times, want 1", successes)
+	}
+}
+
+func TestOOBIssuer_HashKey(t *testing.T) {
+	store := NewMemoryOOBStore()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	issuer := NewOOBIssuer(store, &captureSender{}, &OOBConfig{HashKey: key})
+
+	msg, err := issuer.Issue(context.Background(), "alice", "alice@example.com", "login")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	// The stored hash is keyed: it cannot be recomputed from the salt and the code alone.
+	pending, _ := store.Load("alice", "login")
+	raw, _ := base64.RawStdEncoding.DecodeString(pending.Hash)
+	unkeyed := sha256.Sum256(append(raw[:oobSaltSize:oobSaltSize], msg.Code...))
+	if len(raw) != oobSaltSize+sha256.Size || bytes.Equal(unkeyed[:], raw[oobSaltSize:]) {
+		t.Fatalf("stored hash %q is not keyed", pending.Hash)
+	}
+
+	// Issuers with another key cannot verify the code; those with the same key can.
+	other := NewOOBIssuer(store, &captureSender{}, nil)
+	if err := other.Verify("alice", "login", msg.Code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("other key: expected ErrInvalidCode, got %v", err)
+	}
+	shared := NewOOBIssuer(store, &captureSender{}, &OOBConfig{HashKey: key})
+	if err := shared.Verify("alice", "login", msg.Code); err != nil {
+		t.Errorf("same key: Verify failed: %v", err)
+	}
+}
+
+func TestFormatWebOTP(t *testing.T) {
+	got := FormatWebOTP("Your code is 123456.", "example.com", "123456")
+	if got != "Your code is 123456.\n\n@example.com #123456" {
+		t.Errorf("FormatWebOTP = %q", got)
+	}
+}
+
+func TestWriterAndFileSender(t *testing.T) {
+	msg := OOBMessage{To: "alice@example.com", Subject: "Your code", Body: "Your code is 123456.", Expires: time.Unix(1700000000, 0).UTC()}
+	want := "To: alice@example.com\nSubject: Your code\nExpires: 2023-11-14T22:13:20Z\n\nYour code is 123456.\n\n"
+
+	var buf bytes.Buffer
+	if err := NewWriterSender(&buf).Send(context.Background(), msg); err != nil {
+		t.Fatalf("WriterSender failed: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("WriterSender wrote %q", buf.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "outbox.txt")
+	sender := &FileSender{Path: path}
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(context.Background(), msg); err != nil {
+			t.Fatalf("FileSender failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want+want {
+		t.Errorf("FileSender wrote %q", data)
+	}
+}
+
+// smtpTestServer accepts a single SMTP session on a local port and records it.
+type smtpTestServer struct {
+	addr string
+	done chan struct{}
+	from string
+	rcpt string
+	data string
+}
+
+func newSMTPTestServer(t *testing.T) *smtpTestServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpTestServer{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(conn)
+	}()
+	return s
+}
+
+func (s *smtpTestServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from, _, _ = strings.Cut(strings.TrimLeft(line[len("MAIL FROM:"):], "< "), ">")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt, _, _ = strings.Cut(strings.TrimLeft(line[len("RCPT TO:"):], "< "), ">")
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	server := newSMTPTestServer(t)
+
+	issuer := NewOOBIssuer(nil, &SMTPSender{Addr: server.addr, From: "Example <no-reply@example.com>", AllowPlaintext: true}, &OOBConfig{Issuer: "Example"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := issuer.Issue(ctx, "alice", "alice@example.com", "login")
+	if err != nil {
+		t.Fatalf("Issue over SMTP failed: %v", err)
+	}
+	<-server.done
+
+	if server.from != "no-reply@example.com" || server.rcpt != "alice@example.com" {
+		t.Errorf("envelope from %q to %q", server.from, server.rcpt)
+	}
+	for _, want := range []string{
+		"From: Example <no-reply@example.com>\r\n",
+		"To: alice@example.com\r\n",
+		"Subject: Your Example verification code\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nYour Example verification code for login is " + msg.Code + ".",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("message lacks %q:\n%s", want, server.data)
+		}
+	}
+
+	if err := issuer.Verify("alice", "login", msg.Code); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
+
+func TestSMTPSender_RequireTLSAndAuth(t *testing.T) {
+	msg := OOBMessage{To: "alice@example.com", Subject: "Your code", Body: "Your code is 123456."}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The test server offers neither STARTTLS nor AUTH.
+	for _, sender := range []*SMTPSender{
+		{From: "no-reply@example.com"},
+		{From: "no-reply@example.com", Auth: smtp.CRAMMD5Auth("user", "secret"), AllowPlaintext: true},
+	} {
+		server := newSMTPTestServer(t)
+		sender.Addr = server.addr
+		if err := sender.Send(ctx, msg); err == nil {
+			t.Errorf("%+v: expected error", sender)
+		}
+		<-server.done
+		if server.data != "" || server.from != "" {
+			t.Errorf("%+v: message was sent", sender)
+		}
+	}
+}
+
+func TestSMTPSender_InvalidAddresses(t *testing.T) {
+	sender := &SMTPSender{Addr: "127.0.0.1:1", From: "no-reply@example.com"}
+	for _, to := range []string{"", "alice", "alice@example.com\r\nBcc: eve@example.com"} {
+		if err := sender.Send(context.Background(), OOBMessage{To: to}); err == nil || !strings.Contains(err.Error(), "recipient") {
+			t.Errorf("To %q: expected address error, got %v", to, err)
+		}
+	}
+	if err := sender.Send(context.Background(), OOBMessage{To: "a@example.com", Subject: "x\nBcc: eve@example.com"}); err == nil {
+		t.Error("expected error for subject with newline")
+	}
+}
@@ -13,9 +13,14 @@ import (
 
 type (
 	// Algorithm defines the hashing algorithm used in the HMAC function.
-	// Supported values are SHA1, SHA256, and SHA512, per RFC 6238.
+	// SHA1, SHA256, and SHA512 are built in, per RFC 6238; others can be added
+	// with RegisterAlgorithm.
 	Algorithm uint8
 	Digits    uint8
+
+	// Truncation selects where RFC 4226 truncation reads the 4 bytes of the HMAC.
+	// The zero value is DynamicTruncation.
+	Truncation int16
 )
 
 const (
@@ -36,6 +41,25 @@ const (
 	TenDigits   Digits = 10
 )
 
+// DynamicTruncation is the RFC 4226 §5.3 dynamic truncation: the offset is taken
+// from the low 4 bits of the last byte of the HMAC.
+const DynamicTruncation Truncation = 0
+
+// FixedTruncation returns a Truncation that always reads at offset, like the
+// truncationOffset argument of the RFC 4226 reference implementation. The offset must
+// be less than the HMAC size minus 4 (16 for SHA1).
+func FixedTruncation(offset uint8) Truncation {
+	return Truncation(offset) + 1
+}
+
+// Offset returns the fixed offset and true, or false for dynamic truncation.
+func (t Truncation) Offset() (int, bool) {
+	if t <= DynamicTruncation {
+		return 0, false
+	}
+	return int(t) - 1, true
+}
+
 func (d Digits) Int() int {
 	return int(d)
 }
@@ -55,27 +79,22 @@ func DigitsFromStr(digits string) Digits {
 	}
 }
 
-var algoStrMap = map[Algorithm]string{
-	SHA1:   "SHA1",
-	SHA256: "SHA256",
-	SHA512: "SHA512",
-}
-
+// String returns the registered name of algo, or an empty string if it is unknown.
 func (algo Algorithm) String() string {
-	return algoStrMap[algo]
+	spec, err := lookupAlgorithm(algo)
+	if err != nil {
+		return ""
+	}
+	return spec.name
 }
 
+// AlgorithmFromStr returns the registered Algorithm with the given name.
+// It defaults to SHA1 for unknown names; use ParseAlgorithm to detect them.
 func AlgorithmFromStr(algo string) Algorithm {
-	switch algo {
-	case "SHA1":
-		return SHA1
-	case "SHA256":
-		return SHA256
-	case "SHA512":
-		return SHA512
-	default:
-		return SHA1
+	if a, err := ParseAlgorithm(algo); err == nil {
+		return a
 	}
+	return SHA1
 }
 
 // Param defines configuration parameters for generating and validating OTPs.
@@ -95,14 +114,120 @@ type Param struct {
 
 	// Algorithm specifies which HMAC hashing algorithm to use (SHA1, SHA256, SHA512).
 	Algorithm Algorithm
+
+	// Encoder renders codes from the HMAC output. Nil means DecimalEncoder,
+	// the RFC 4226 decimal format. Digits is then the code length in characters.
+	Encoder Encoder
+
+	// Checksum appends the RFC 4226 reference implementation's Luhn check digit to
+	// decimal codes, which are then Digits+1 characters long. Ignored by other encoders.
+	Checksum bool
+
+	// Truncation selects the truncation offset of decimal codes. The zero value is
+	// DynamicTruncation; see FixedTruncation. Ignored by other encoders.
+	Truncation Truncation
+
+	// T0 is the Unix time, in seconds, from which TOTP time steps are counted
+	// (RFC 6238 §4.1). Zero means the Unix epoch, in which case TimeCounterFunc is used.
+	T0 int64
+
+	// Clock returns the current time. It is used when a zero time.Time is passed to
+	// GenerateTOTP, ValidateTOTP and the other time-based functions, so that tests and
+	// callers can inject time per credential. Nil means time.Now.
+	Clock func() time.Time
+
+	// ConstantTime makes validation evaluate every step of the skew window and select
+	// the match with constant-time operations, instead of returning at the first match.
+	// Response time then no longer reveals whether, or at which offset, a code matched.
+	// The cost is that every validation takes as long as a failed one: 2*Skew+1 code
+	// computations (see BenchmarkValidateTOTP_ConstantTime), or Skew+1 for the
+	// forward-only windows of HOTPVerifier and ResyncHOTP.
+	ConstantTime bool
+}
+
+// timeOrNow returns t, or the current time from p.Clock if t is zero.
+func (p *Param) timeOrNow(t time.Time) time.Time {
+	if !t.IsZero() {
+		return t
+	}
+	if p.Clock != nil {
+		return p.Clock()
+	}
+	return time.Now()
+}
+
+// timeCounter returns the number of period-second steps between p.T0 and t,
+// falling back to p.Clock for a zero t.
+func (p *Param) timeCounter(t time.Time, period uint) (uint64, error) {
+	t = p.timeOrNow(t)
+	if p.T0 == 0 {
+		return TimeCounterFunc(t, period), nil
+	}
+	if t.Unix() < p.T0 {
+		return 0, ErrTimeBeforeT0
+	}
+	return uint64(t.Unix()-p.T0) / uint64(period), nil
+}
+
+// stepStart returns the start time of the TOTP step counter.
+func (p *Param) stepStart(counter uint64, period uint) time.Time {
+	return time.Unix(p.T0+int64(counter*uint64(period)), 0)
+}
+
+// codeLength returns the length of the codes produced with p.
+func (p *Param) codeLength() int {
+	if p.Checksum && p.decimal() {
+		return p.Digits.Int() + 1
+	}
+	return p.Digits.Int()
+}
+
+// decimal reports whether p produces RFC 4226 decimal codes, i.e. has no Encoder or
+// DecimalEncoder set explicitly.
+func (p *Param) decimal() bool {
+	return p.Encoder == nil || p.Encoder == DecimalEncoder
+}
+
+// validDigits reports whether p.Digits is a code length its encoder can produce: 1 to 10
+// decimal digits, or up to MaxLength characters for an AlphabetEncoder.
+func (p *Param) validDigits() bool {
+	digits := p.Digits.Int()
+	if digits < 1 {
+		return false
+	}
+	if p.decimal() {
+		return digits < len(mod10)
+	}
+	if e, ok := p.Encoder.(*AlphabetEncoder); ok {
+		return digits <= e.MaxLength()
+	}
+	return true
 }
 
 // TimeCounterFunc returns the TOTP counter value based on the Unix time and period.
 // It performs integer division of time by the period to produce a moving counter window.
+//
+// It is global and only used for params with a zero T0; prefer Param.T0 and Param.Clock
+// to change the epoch or inject time for a single credential.
 var TimeCounterFunc = func(t time.Time, period uint) uint64 {
 	return uint64(t.Unix()) / uint64(period)
 }
 
+// ValidationResult describes which step of the validation window matched a code.
+type ValidationResult struct {
+	// Counter is the HOTP counter or TOTP time step that produced the code.
+	Counter uint64
+
+	// Offset is the signed distance between Counter and the expected counter
+	// (0 means an exact match, -1 one step behind, +1 one step ahead).
+	Offset int64
+
+	// StepStart and StepEnd bound the matched TOTP time step.
+	// They are zero for HOTP.
+	StepStart time.Time
+	StepEnd   time.Time
+}
+
 type URLParam struct {
 	// Name of the issuing Organization/Company.
 	Issuer string
@@ -116,6 +241,16 @@ type URLParam struct {
 	Digits Digits
 	// Algorithm to use for HMAC. Defaults to SHA1.
 	Algorithm Algorithm
+	// Encoder is the non-standard `encoder` extension, e.g. "steam" for Steam Guard.
+	// Empty means standard decimal codes.
+	Encoder string
+	// Counter is the initial HOTP counter. It is not used for TOTP.
+	Counter uint64
+	// Image is the URL of an issuer logo (`image` extension, e.g. FreeOTP). Optional.
+	Image string
+	// Color is the background color of the account as 6 hex digits, RRGGBB (`color`
+	// extension, e.g. FreeOTP). Optional.
+	Color string
 }
 
 // ChallengeFormat enumerates the possible challenge formats.
@@ -279,17 +414,9 @@ func challengeLength(format ChallengeFormat) int {
 // RandomSecret returns a base32-encoded random secret for the given algorithm.
 // The secret is of appropriate byte length for RFC-compliant HOTP/TOTP implementations.
 func RandomSecret(algo Algorithm) (string, error) {
-	size := 20
-
-	switch algo {
-	case SHA1:
-		size = 20
-	case SHA256:
-		size = 32
-	case SHA512:
-		size = 64
-	default:
-		return "", ErrUnsupportedAlgorithm
+	size, err := secretSize(algo)
+	if err != nil {
+		return "", err
 	}
 
 	secret := make([]byte, size)
@@ -300,9 +427,41 @@ func RandomSecret(algo Algorithm) (string, error) {
 	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
 }
 
-// ParseOTPAuthURL parses an otpauth:// URL (TOTP or HOTP) and converts it into a URLParam struct.
-// Supported format: otpauth://TYPE/LABEL?secret=...&issuer=...&digits=...&algorithm=...&period=...
+// secretSize returns the recommended secret length in bytes for algo,
+// i.e. the output size of the underlying hash.
+func secretSize(algo Algorithm) (int, error) {
+	spec, err := lookupAlgorithm(algo)
+	if err != nil {
+		return 0, err
+	}
+	return spec.size, nil
+}
+
+// ParseOTPAuthURL parses an otpauth:// URL (TOTP or HOTP) and converts it into a URLParam struct,
+// following the Key Uri Format used by Google Authenticator and compatible apps:
+//
+//	otpauth://TYPE/[ISSUER:]ACCOUNT?secret=...&issuer=...&digits=...&algorithm=...&period=...&counter=...
+//
+// The label may be percent-encoded and contain any Unicode text; the colon separating
+// issuer and account may be encoded as %3A and followed by spaces. When both the label
+// prefix and the issuer parameter are present, the issuer parameter takes precedence.
+// The FreeOTP `image` and `color` extensions are parsed into Image and Color.
+//
+// Parsing is lenient, accepting what common apps accept; see ParseOTPAuthURLStrict.
 func ParseOTPAuthURL(u *url.URL) (*URLParam, error) {
+	return parseOTPAuthURL(u, false)
+}
+
+// ParseOTPAuthURLStrict is like ParseOTPAuthURL but rejects URLs that some authenticator
+// apps would misread: a missing or non-base32 secret, an issuer parameter that does not
+// match the label prefix, digits other than 6 or 8 (5 for Steam), an unknown encoder
+// (see ParseEncoder), an HOTP URL without counter, a color that is not 6 hex digits or
+// an image that is not an http(s) URL.
+func ParseOTPAuthURLStrict(u *url.URL) (*URLParam, error) {
+	return parseOTPAuthURL(u, true)
+}
+
+func parseOTPAuthURL(u *url.URL, strict bool) (*URLParam, error) {
 	if u == nil {
 		return nil, fmt.Errorf("nil URL provided")
 	}
@@ -311,19 +470,31 @@ func ParseOTPAuthURL(u *url.URL) (*URLParam, error) {
 	}
 
 	otpType := strings.ToLower(u.Host)
-	if otpType != "totp" && otpType != "hotp" {
+	if otpType != "totp" && otpType != "hotp" && otpType != SteamEncoderName {
 		return nil, fmt.Errorf("unsupported OTP type: %s", otpType)
 	}
 
-	// Parse label
-	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), ":", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid label format, expected Issuer:AccountName")
+	// Parse label; u.Path is already percent-decoded.
+	label := strings.TrimPrefix(u.Path, "/")
+	issuer, accountName, hasIssuer := strings.Cut(label, ":")
+	if !hasIssuer {
+		issuer, accountName = "", label
+	}
+	accountName = strings.TrimLeft(accountName, " ")
+	if accountName == "" {
+		return nil, fmt.Errorf("invalid label format, expected [Issuer:]AccountName")
 	}
-	issuer, accountName := parts[0], parts[1]
 
 	query := u.Query()
 
+	if query.Has("issuer") {
+		issuerParam := query.Get("issuer")
+		if strict && hasIssuer && issuerParam != issuer {
+			return nil, fmt.Errorf("%w: label %q, parameter %q", ErrIssuerMismatch, issuer, issuerParam)
+		}
+		issuer = issuerParam
+	}
+
 	param := &URLParam{
 		Issuer:      issuer,
 		AccountName: accountName,
@@ -331,40 +502,120 @@ func ParseOTPAuthURL(u *url.URL) (*URLParam, error) {
 		Digits:      SixDigits,
 		Algorithm:   SHA1,
 		Period:      30,
+		Encoder:     strings.ToLower(query.Get("encoder")),
+		Image:       query.Get("image"),
+		Color:       query.Get("color"),
+	}
+
+	if strict {
+		if param.Secret == "" {
+			return nil, ErrSecretRequired
+		}
+		if _, err := DecodeSecret(param.Secret); err != nil {
+			return nil, fmt.Errorf("invalid secret: %w", err)
+		}
+	}
+
+	// Some apps export Steam Guard accounts as otpauth://steam/ instead of encoder=steam.
+	if otpType == SteamEncoderName {
+		param.Encoder = SteamEncoderName
+	}
+	if param.Encoder == SteamEncoderName {
+		param.Digits = DefaultSteamParam.Digits
 	}
 
 	if digitsStr := query.Get("digits"); digitsStr != "" {
-		if digitsInt, err := strconv.Atoi(digitsStr); err == nil {
-			param.Digits = Digits(digitsInt)
-		} else {
+		digitsInt, err := strconv.Atoi(digitsStr)
+		if err != nil || digitsInt < 1 || digitsInt > maxURLDigits {
 			return nil, fmt.Errorf("invalid digits value: %s", digitsStr)
 		}
+		param.Digits = Digits(digitsInt)
+	}
+	if strict && !standardURLDigits(param) {
+		return nil, fmt.Errorf("unsupported digits value: %d", param.Digits)
+	}
+	// Apps that do not know an encoder render decimal codes, so unknown encoders are
+	// bounded like the decimal one.
+	enc, err := ParseEncoder(param.Encoder)
+	if err != nil && strict {
+		return nil, err
+	}
+	if (err != nil || enc == DecimalEncoder) && param.Digits.Int() >= len(mod10) {
+		return nil, fmt.Errorf("invalid digits value: %d", param.Digits)
 	}
 
 	if algStr := query.Get("algorithm"); algStr != "" {
-		switch strings.ToUpper(algStr) {
-		case "SHA1":
-			param.Algorithm = SHA1
-		case "SHA256":
-			param.Algorithm = SHA256
-		case "SHA512":
-			param.Algorithm = SHA512
-		default:
+		algo, err := ParseAlgorithm(algStr)
+		if err != nil {
 			return nil, fmt.Errorf("unsupported algorithm: %s", algStr)
 		}
+		param.Algorithm = algo
 	}
 
 	if periodStr := query.Get("period"); periodStr != "" {
-		if p, err := strconv.Atoi(periodStr); err == nil {
+		if p, err := strconv.ParseUint(periodStr, 10, 32); err == nil && p > 0 {
 			param.Period = uint(p)
 		} else {
 			return nil, fmt.Errorf("invalid period value: %s", periodStr)
 		}
 	}
 
+	if counterStr := query.Get("counter"); counterStr != "" {
+		counter, err := strconv.ParseUint(counterStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter value: %s", counterStr)
+		}
+		param.Counter = counter
+	} else if strict && otpType == "hotp" {
+		return nil, fmt.Errorf("counter is required for HOTP")
+	}
+
+	if strict {
+		if err := checkURLImage(param.Image); err != nil {
+			return nil, err
+		}
+		if err := checkURLColor(param.Color); err != nil {
+			return nil, err
+		}
+	}
+
 	return param, nil
 }
 
+// maxURLDigits bounds the digits parameter of otpauth URLs; alphabet encoders are not
+// limited by the decimal range.
+const maxURLDigits = 32
+
+// standardURLDigits reports whether the digits of param are those of the Key Uri Format
+// (6 or 8), or Steam Guard's 5.
+func standardURLDigits(param *URLParam) bool {
+	if param.Encoder == SteamEncoderName {
+		return param.Digits == DefaultSteamParam.Digits
+	}
+	return param.Digits == SixDigits || param.Digits == EightDigits
+}
+
+func checkURLImage(image string) error {
+	if image == "" {
+		return nil
+	}
+	u, err := url.Parse(image)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid image URL: %s", image)
+	}
+	return nil
+}
+
+func checkURLColor(color string) error {
+	if color == "" {
+		return nil
+	}
+	if _, err := hex.DecodeString(color); err != nil || len(color) != 6 {
+		return fmt.Errorf("invalid color value, expected RRGGBB: %s", color)
+	}
+	return nil
+}
+
 func generateOTPURL(kind string, param URLParam, extraParams map[string]string) (*url.URL, error) {
 	if param.Issuer == "" {
 		return nil, ErrIssuerRequired
@@ -381,14 +632,35 @@ func generateOTPURL(kind string, param URLParam, extraParams map[string]string)
 	if param.Secret == "" {
 		return nil, ErrSecretRequired
 	}
+	if err := checkURLImage(param.Image); err != nil {
+		return nil, err
+	}
+	if err := checkURLColor(param.Color); err != nil {
+		return nil, err
+	}
 
-	label := url.PathEscape(fmt.Sprintf("%s:%s", param.Issuer, param.AccountName))
+	// The label is "Issuer:AccountName", or just the account name if the issuer
+	// contains a colon; the issuer parameter is authoritative either way.
+	label, rawLabel := param.AccountName, url.PathEscape(param.AccountName)
+	if !strings.Contains(param.Issuer, ":") {
+		label = param.Issuer + ":" + label
+		rawLabel = url.PathEscape(param.Issuer) + ":" + rawLabel
+	}
 
 	query := url.Values{}
 	query.Set("secret", param.Secret)
 	query.Set("issuer", param.Issuer)
 	query.Set("algorithm", param.Algorithm.String())
 	query.Set("digits", fmt.Sprintf("%d", param.Digits))
+	if param.Encoder != "" {
+		query.Set("encoder", param.Encoder)
+	}
+	if param.Image != "" {
+		query.Set("image", param.Image)
+	}
+	if param.Color != "" {
+		query.Set("color", param.Color)
+	}
 
 	// Add type-specific values
 	for k, v := range extraParams {
@@ -399,6 +671,7 @@ func generateOTPURL(kind string, param URLParam, extraParams map[string]string)
 		Scheme:   "otpauth",
 		Host:     kind, // "totp" or "hotp"
 		Path:     "/" + label,
+		RawPath:  "/" + rawLabel,
 		RawQuery: query.Encode(),
 	}, nil
 }
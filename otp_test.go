@@ -3,6 +3,7 @@ package otp
 import (
 	"encoding/base32"
 	"encoding/hex"
+	"errors"
 	"net/url"
 	"strings"
 	"testing"
@@ -209,3 +210,146 @@ func TestHexInputToOCRA(t *testing.T) {
 func containsIgnoreCase(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
+
+func TestParseOTPAuthURL_KeyUriFormat(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+
+	tests := []struct {
+		name        string
+		rawURL      string
+		issuer      string
+		account     string
+		counter     uint64
+		digits      Digits
+		image       string
+		color       string
+		wantErr     bool
+		wantStrict  bool // strict parsing also succeeds
+		strictError error
+	}{
+		{name: "issuer parameter only", rawURL: "otpauth://totp/alice@example.com?secret=" + secret + "&issuer=Example", issuer: "Example", account: "alice@example.com", wantStrict: true},
+		{name: "bare label", rawURL: "otpauth://totp/alice?secret=" + secret, account: "alice", wantStrict: true},
+		{name: "encoded colon and space", rawURL: "otpauth://totp/ACME%20Co%3A%20john.doe@email.com?secret=" + secret + "&issuer=ACME%20Co", issuer: "ACME Co", account: "john.doe@email.com", wantStrict: true},
+		{name: "unicode label", rawURL: "otpauth://totp/%E4%BE%8B%E5%AD%90:%C3%A9lise?secret=" + secret, issuer: "例子", account: "élise", wantStrict: true},
+		{name: "issuer parameter wins", rawURL: "otpauth://totp/Old:alice?secret=" + secret + "&issuer=New", issuer: "New", account: "alice", strictError: ErrIssuerMismatch},
+		{name: "hotp counter", rawURL: "otpauth://hotp/Example:alice?secret=" + secret + "&counter=42", issuer: "Example", account: "alice", counter: 42, wantStrict: true},
+		{name: "hotp without counter", rawURL: "otpauth://hotp/Example:alice?secret=" + secret, issuer: "Example", account: "alice"},
+		{name: "image and color", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&image=https%3A%2F%2Fexample.com%2Flogo.png&color=1a2B3c", issuer: "Example", account: "alice", image: "https://example.com/logo.png", color: "1a2B3c", wantStrict: true},
+		{name: "invalid color", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&color=red", issuer: "Example", account: "alice", color: "red"},
+		{name: "invalid image", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&image=javascript:alert(1)", issuer: "Example", account: "alice", image: "javascript:alert(1)"},
+		{name: "seven digits", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&digits=7", issuer: "Example", account: "alice", digits: 7},
+		{name: "missing secret", rawURL: "otpauth://totp/Example:alice", issuer: "Example", account: "alice", strictError: ErrSecretRequired},
+		{name: "invalid secret", rawURL: "otpauth://totp/Example:alice?secret=not-base32", issuer: "Example", account: "alice"},
+		{name: "empty account", rawURL: "otpauth://totp/Example:?secret=" + secret, wantErr: true},
+		{name: "unsupported digits", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&digits=12", wantErr: true},
+		{name: "zero digits", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&digits=0", wantErr: true},
+		{name: "zero period", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&period=0", wantErr: true},
+		{name: "invalid counter", rawURL: "otpauth://hotp/Example:alice?secret=" + secret + "&counter=-1", wantErr: true},
+		{name: "base32 encoder", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&encoder=base32&digits=16", issuer: "Example", account: "alice", digits: 16},
+		{name: "unknown encoder", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&encoder=base64", issuer: "Example", account: "alice", strictError: ErrUnsupportedEncoder},
+		{name: "unknown encoder with long digits", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&encoder=foo&digits=20", wantErr: true},
+		{name: "decimal encoder with long digits", rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&encoder=decimal&digits=12", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.rawURL)
+			if err != nil {
+				t.Fatalf("failed to parse input URL: %v", err)
+			}
+
+			got, err := ParseOTPAuthURL(u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error mismatch: got %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			digits := tt.digits
+			if digits == 0 {
+				digits = SixDigits
+			}
+			if got.Issuer != tt.issuer || got.AccountName != tt.account || got.Counter != tt.counter ||
+				got.Digits != digits || got.Image != tt.image || got.Color != tt.color {
+				t.Errorf("parsed output mismatch: %+v", got)
+			}
+
+			_, err = ParseOTPAuthURLStrict(u)
+			if (err == nil) != tt.wantStrict {
+				t.Errorf("strict: got error %v, want success %v", err, tt.wantStrict)
+			}
+			if tt.strictError != nil && !errors.Is(err, tt.strictError) {
+				t.Errorf("strict: got error %v, want %v", err, tt.strictError)
+			}
+		})
+	}
+}
+
+func TestOTPAuthURL_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		gen   func(URLParam) (*url.URL, error)
+		param URLParam
+		raw   string
+	}{
+		{
+			name:  "hotp counter",
+			gen:   GenerateHOTPURL,
+			param: URLParam{Issuer: "Example", AccountName: "alice@example.com", Secret: "JBSWY3DPEHPK3PXP", Counter: 42},
+			raw:   "otpauth://hotp/Example:alice@example.com?algorithm=SHA1&counter=42&digits=6&issuer=Example&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			name:  "spaces and unicode",
+			gen:   GenerateTOTPURL,
+			param: URLParam{Issuer: "ACME Co", AccountName: "élise", Secret: "JBSWY3DPEHPK3PXP", Period: 30},
+			raw:   "otpauth://totp/ACME%20Co:%C3%A9lise?algorithm=SHA1&digits=6&issuer=ACME+Co&period=30&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			name:  "issuer with colon",
+			gen:   GenerateTOTPURL,
+			param: URLParam{Issuer: "https://example.com", AccountName: "foobar", Secret: "JBSWY3DPEHPK3PXP", Period: 30},
+			raw:   "otpauth://totp/foobar?algorithm=SHA1&digits=6&issuer=https%3A%2F%2Fexample.com&period=30&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			name:  "image and color",
+			gen:   GenerateTOTPURL,
+			param: URLParam{Issuer: "Example", AccountName: "alice", Secret: "JBSWY3DPEHPK3PXP", Period: 30, Image: "https://example.com/logo.png", Color: "1A2B3C"},
+			raw:   "otpauth://totp/Example:alice?algorithm=SHA1&color=1A2B3C&digits=6&image=https%3A%2F%2Fexample.com%2Flogo.png&issuer=Example&period=30&secret=JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := tt.gen(tt.param)
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			if u.String() != tt.raw {
+				t.Errorf("URL = %s\n want %s", u, tt.raw)
+			}
+
+			reparsed, _ := url.Parse(u.String())
+			got, err := ParseOTPAuthURLStrict(reparsed)
+			if err != nil {
+				t.Fatalf("ParseOTPAuthURLStrict failed: %v", err)
+			}
+
+			want := tt.param
+			want.Digits, want.Algorithm = SixDigits, SHA1
+			if want.Period == 0 {
+				want.Period = 30
+			}
+			if *got != want {
+				t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := GenerateTOTPURL(URLParam{Issuer: "Example", AccountName: "alice", Secret: "JBSWY3DPEHPK3PXP", Color: "#fff"}); err == nil {
+		t.Error("expected error for invalid color")
+	}
+	if _, err := GenerateTOTPURL(URLParam{Issuer: "Example", AccountName: "alice", Secret: "JBSWY3DPEHPK3PXP", Image: "logo.png"}); err == nil {
+		t.Error("expected error for relative image URL")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package otp
+
+// raceEnabled reports whether the race detector is enabled.
+const raceEnabled = true
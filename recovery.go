@@ -0,0 +1,397 @@
+package otp
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RecoveryAlphabet is the default recovery code alphabet: lower-case letters and digits
+// without the easily confused i, l, o, 0 and 1.
+const RecoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// DefaultRecoveryConfig generates 10 codes of 10 characters from RecoveryAlphabet
+// (about 49 bits each), displayed as "abcde-fghij".
+var DefaultRecoveryConfig = &RecoveryConfig{
+	Count:     10,
+	Length:    10,
+	Alphabet:  RecoveryAlphabet,
+	GroupSize: 5,
+	Separator: "-",
+}
+
+// RecoveryConfig configures the generation and display of recovery codes.
+type RecoveryConfig struct {
+	// Count is the number of codes per set.
+	Count int
+
+	// Length is the number of random characters per code, excluding separators.
+	Length int
+
+	// Alphabet holds the characters codes are drawn from, uniformly. Empty means
+	// RecoveryAlphabet. If it has no upper-case letters, input is matched case-insensitively.
+	Alphabet string
+
+	// GroupSize splits displayed codes into groups of that many characters, joined by
+	// Separator. Zero disables grouping.
+	GroupSize int
+
+	// Separator joins the groups of a displayed code. It is ignored on input, along
+	// with white space.
+	Separator string
+
+	// Hasher hashes codes for storage. Nil means a PBKDF2RecoveryHasher with
+	// DefaultRecoveryIterations.
+	Hasher RecoveryHasher
+}
+
+func (c *RecoveryConfig) validate() error {
+	if c.Count < 1 || c.Length < 1 {
+		return fmt.Errorf("invalid recovery code count %d or length %d", c.Count, c.Length)
+	}
+	if c.GroupSize < 0 {
+		return fmt.Errorf("invalid recovery code group size %d", c.GroupSize)
+	}
+
+	alphabet := c.alphabet()
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return ErrInvalidAlphabet
+	}
+	seen := [256]bool{}
+	for i := 0; i < len(alphabet); i++ {
+		ch := alphabet[i]
+		if ch <= ' ' || ch > '~' || seen[ch] || strings.IndexByte(c.Separator, ch) >= 0 {
+			return ErrInvalidAlphabet
+		}
+		seen[ch] = true
+	}
+
+	return nil
+}
+
+func (c *RecoveryConfig) alphabet() string {
+	if c.Alphabet == "" {
+		return RecoveryAlphabet
+	}
+	return c.Alphabet
+}
+
+func (c *RecoveryConfig) hasher() RecoveryHasher {
+	if c.Hasher == nil {
+		return PBKDF2RecoveryHasher{}
+	}
+	return c.Hasher
+}
+
+// GenerateRecoveryCodes returns cfg.Count random recovery codes, formatted for display.
+// If cfg is nil, DefaultRecoveryConfig is used.
+func GenerateRecoveryCodes(cfg *RecoveryConfig) ([]string, error) {
+	if cfg == nil {
+		cfg = DefaultRecoveryConfig
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, cfg.Count)
+	for i := range codes {
+		code, err := randomString(cfg.alphabet(), cfg.Length)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = FormatRecoveryCode(code, cfg)
+	}
+
+	return codes, nil
+}
+
+// randomString returns n characters drawn uniformly from alphabet using crypto/rand.
+func randomString(alphabet string, n int) (string, error) {
+	// Reject bytes beyond the largest multiple of len(alphabet) to avoid modulo bias.
+	limit := 256 - 256%len(alphabet)
+
+	out := make([]byte, 0, n)
+	buf := make([]byte, n+n/2)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(out) < n {
+				out = append(out, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+
+	return string(out), nil
+}
+
+// FormatRecoveryCode groups a code for display, e.g. "abcdefgh" as "abcd-efgh" with a
+// GroupSize of 4. If cfg is nil, DefaultRecoveryConfig is used.
+func FormatRecoveryCode(code string, cfg *RecoveryConfig) string {
+	if cfg == nil {
+		cfg = DefaultRecoveryConfig
+	}
+	code = NormalizeRecoveryCode(code, cfg)
+	if cfg.GroupSize <= 0 || len(code) <= cfg.GroupSize {
+		return code
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(code); i += cfg.GroupSize {
+		if i > 0 {
+			sb.WriteString(cfg.Separator)
+		}
+		sb.WriteString(code[i:min(i+cfg.GroupSize, len(code))])
+	}
+	return sb.String()
+}
+
+// NormalizeRecoveryCode strips white space and separators from a code as typed by a user
+// and lower-cases it if the alphabet is case-insensitive. If cfg is nil,
+// DefaultRecoveryConfig is used.
+func NormalizeRecoveryCode(code string, cfg *RecoveryConfig) string {
+	if cfg == nil {
+		cfg = DefaultRecoveryConfig
+	}
+	if cfg.Separator != "" {
+		code = strings.ReplaceAll(code, cfg.Separator, "")
+	}
+	code = strings.Join(strings.Fields(code), "")
+
+	if alphabet := cfg.alphabet(); strings.ToLower(alphabet) == alphabet {
+		code = strings.ToLower(code)
+	}
+	return code
+}
+
+// RecoveryHasher hashes recovery codes for storage, like a password hash.
+// Hash must be salted; Verify must run in time independent of where code and hash differ.
+type RecoveryHasher interface {
+	// Hash returns a self-describing hash of the normalized code.
+	Hash(code string) (string, error)
+
+	// Verify reports whether code matches hash.
+	Verify(code, hash string) (bool, error)
+}
+
+// DefaultRecoveryIterations is the PBKDF2 iteration count used when none is set.
+// Recovery codes are random and long, unlike passwords, so a moderate work factor is
+// enough; every Verify tries all remaining codes of a credential.
+const DefaultRecoveryIterations = 10000
+
+// recoverySaltSize and recoveryHashSize are the PBKDF2 salt and output sizes in bytes.
+const (
+	recoverySaltSize = 16
+	recoveryHashSize = 32
+)
+
+// PBKDF2RecoveryHasher hashes codes with PBKDF2-HMAC-SHA256 (RFC 8018) and a random salt,
+// encoded as "pbkdf2-sha256$<iterations>$<salt>$<hash>" in unpadded base64.
+type PBKDF2RecoveryHasher struct {
+	// Iterations is the PBKDF2 iteration count. Zero means DefaultRecoveryIterations.
+	Iterations int
+}
+
+// Hash implements RecoveryHasher.
+func (h PBKDF2RecoveryHasher) Hash(code string) (string, error) {
+	iter := h.Iterations
+	if iter <= 0 {
+		iter = DefaultRecoveryIterations
+	}
+
+	salt := make([]byte, recoverySaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	sum, err := pbkdf2.Key(sha256.New, code, salt, iter, recoveryHashSize)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawStdEncoding
+	return "pbkdf2-sha256$" + strconv.Itoa(iter) + "$" + enc.EncodeToString(salt) + "$" + enc.EncodeToString(sum), nil
+}
+
+// Verify implements RecoveryHasher.
+func (h PBKDF2RecoveryHasher) Verify(code, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false, fmt.Errorf("unsupported recovery code hash format")
+	}
+
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false, fmt.Errorf("invalid PBKDF2 iteration count %q", parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, fmt.Errorf("invalid PBKDF2 salt: %w", err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false, fmt.Errorf("invalid PBKDF2 hash")
+	}
+
+	sum, err := pbkdf2.Key(sha256.New, code, salt, iter, len(want))
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(sum, want) == 1, nil
+}
+
+// RecoveryStore persists the hashed, unused recovery codes of each credential.
+//
+// Implementations must be safe for concurrent use. Delete must be atomic, e.g. a
+// `DELETE ... WHERE id = ? AND hash = ?` whose affected row count is checked.
+type RecoveryStore interface {
+	// Load returns the hashes of the unused recovery codes of id.
+	Load(id string) ([]string, error)
+
+	// Replace discards all recovery codes of id and stores hashes instead.
+	Replace(id string, hashes []string) error
+
+	// Delete removes hash from the codes of id and reports whether it was still present.
+	Delete(id, hash string) (bool, error)
+}
+
+// RecoveryVerifier issues recovery codes, stores them hashed in a RecoveryStore and
+// consumes each of them at most once.
+type RecoveryVerifier struct {
+	store RecoveryStore
+	cfg   RecoveryConfig
+}
+
+// NewRecoveryVerifier returns a RecoveryVerifier using store for hashed codes.
+// If store is nil, a new in-memory store is used. If cfg is nil, DefaultRecoveryConfig
+// is used.
+func NewRecoveryVerifier(store RecoveryStore, cfg *RecoveryConfig) *RecoveryVerifier {
+	if store == nil {
+		store = NewMemoryRecoveryStore()
+	}
+	if cfg == nil {
+		cfg = DefaultRecoveryConfig
+	}
+	return &RecoveryVerifier{store: store, cfg: *cfg}
+}
+
+// Generate issues a new set of recovery codes for id, replacing any previous set, and
+// returns them formatted for display. The codes are only stored hashed, so they must
+// be shown to the user now.
+func (v *RecoveryVerifier) Generate(id string) ([]string, error) {
+	codes, err := GenerateRecoveryCodes(&v.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := v.cfg.hasher()
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		if hashes[i], err = hasher.Hash(NormalizeRecoveryCode(code, &v.cfg)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.store.Replace(id, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks code against the unused recovery codes of id and, on success, consumes
+// it and returns how many codes are left. It fails with ErrInvalidRecoveryCode if code
+// matches none of them, and with ErrCodeReused if a concurrent call consumed it first.
+//
+// Code is compared against every stored hash, whatever the outcome, and the match is
+// selected with constant-time operations, so timing does not reveal which code matched.
+func (v *RecoveryVerifier) Verify(id, code string) (int, error) {
+	hashes, err := v.store.Load(id)
+	if err != nil {
+		return 0, err
+	}
+
+	code = NormalizeRecoveryCode(code, &v.cfg)
+	hasher := v.cfg.hasher()
+
+	found, index := 0, 0
+	for i, hash := range hashes {
+		ok, err := hasher.Verify(code, hash)
+		if err != nil {
+			return 0, err
+		}
+		take := int(boolByte(ok)) &^ found
+		index = subtle.ConstantTimeSelect(take, i, index)
+		found |= take
+	}
+	if found == 0 {
+		return len(hashes), ErrInvalidRecoveryCode
+	}
+
+	deleted, err := v.store.Delete(id, hashes[index])
+	if err != nil {
+		return 0, err
+	}
+	if !deleted {
+		return 0, ErrCodeReused
+	}
+
+	return len(hashes) - 1, nil
+}
+
+// Remaining returns the number of unused recovery codes of id.
+func (v *RecoveryVerifier) Remaining(id string) (int, error) {
+	hashes, err := v.store.Load(id)
+	if err != nil {
+		return 0, err
+	}
+	return len(hashes), nil
+}
+
+// MemoryRecoveryStore is an in-memory RecoveryStore.
+type MemoryRecoveryStore struct {
+	mu     sync.Mutex
+	hashes map[string][]string
+}
+
+// NewMemoryRecoveryStore returns an empty in-memory RecoveryStore.
+func NewMemoryRecoveryStore() *MemoryRecoveryStore {
+	return &MemoryRecoveryStore{hashes: make(map[string][]string)}
+}
+
+// Load implements RecoveryStore.
+func (s *MemoryRecoveryStore) Load(id string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.hashes[id]...), nil
+}
+
+// Replace implements RecoveryStore.
+func (s *MemoryRecoveryStore) Replace(id string, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[id] = append([]string(nil), hashes...)
+	return nil
+}
+
+// Delete implements RecoveryStore.
+func (s *MemoryRecoveryStore) Delete(id, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashes := s.hashes[id]
+	for i, h := range hashes {
+		if h == hash {
+			s.hashes[id] = append(hashes[:i:i], hashes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
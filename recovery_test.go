@@ -0,0 +1,197 @@
+package otp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fastRecoveryConfig keeps PBKDF2 cheap in tests.
+var fastRecoveryConfig = &RecoveryConfig{
+	Count:     10,
+	Length:    10,
+	GroupSize: 5,
+	Separator: "-",
+	Hasher:    PBKDF2RecoveryHasher{Iterations: 1},
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(nil)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		for _, c := range strings.ReplaceAll(code, "-", "") {
+			if !strings.ContainsRune(RecoveryAlphabet, c) {
+				t.Errorf("code %q has character %q outside the alphabet", code, c)
+			}
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	codes, err = GenerateRecoveryCodes(&RecoveryConfig{Count: 3, Length: 12, Alphabet: "0123456789", GroupSize: 4, Separator: " "})
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes failed: %v", err)
+	}
+	if len(codes) != 3 || len(codes[0]) != 14 || strings.Count(codes[0], " ") != 2 {
+		t.Errorf("unexpected custom codes %q", codes)
+	}
+
+	invalid := []*RecoveryConfig{
+		{Count: 0, Length: 10},
+		{Count: 1, Length: 0},
+		{Count: 1, Length: 10, Alphabet: "a"},
+		{Count: 1, Length: 10, Alphabet: "aab"},
+		{Count: 1, Length: 10, Alphabet: "ab-", Separator: "-"},
+		{Count: 1, Length: 10, Alphabet: "ab c"},
+		{Count: 1, Length: 10, GroupSize: -1},
+	}
+	for _, cfg := range invalid {
+		if _, err := GenerateRecoveryCodes(cfg); err == nil {
+			t.Errorf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestFormatRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in   string
+		cfg  *RecoveryConfig
+		want string
+	}{
+		{"abcdefgh", &RecoveryConfig{GroupSize: 4, Separator: "-"}, "abcd-efgh"},
+		{"abcdefghij", nil, "abcde-fghij"},
+		{"abcdefghijk", nil, "abcde-fghij-k"},
+		{"abcd", nil, "abcd"},
+		{" ABCDE-fghij ", nil, "abcde-fghij"},
+		{"abcdefgh", &RecoveryConfig{Separator: "-"}, "abcdefgh"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatRecoveryCode(tt.in, tt.cfg); got != tt.want {
+			t.Errorf("FormatRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if got := NormalizeRecoveryCode("AbCd Ef", &RecoveryConfig{Alphabet: "ABCDEFabcdef"}); got != "AbCdEf" {
+		t.Errorf("case-sensitive alphabet: got %q", got)
+	}
+}
+
+func TestPBKDF2RecoveryHasher(t *testing.T) {
+	h := PBKDF2RecoveryHasher{Iterations: 1000}
+
+	hash, err := h.Hash("abcdefghij")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") {
+		t.Errorf("unexpected hash format %q", hash)
+	}
+	if again, _ := h.Hash("abcdefghij"); again == hash {
+		t.Error("hashes of the same code must differ by salt")
+	}
+
+	// The iteration count is read from the hash, not the hasher.
+	if ok, err := (PBKDF2RecoveryHasher{}).Verify("abcdefghij", hash); !ok || err != nil {
+		t.Errorf("Verify = %v, %v", ok, err)
+	}
+	if ok, err := h.Verify("abcdefghik", hash); ok || err != nil {
+		t.Errorf("Verify wrong code = %v, %v", ok, err)
+	}
+
+	for _, bad := range []string{"", "bcrypt$x", "pbkdf2-sha256$0$AAAA$AAAA", "pbkdf2-sha256$10$!$AAAA", "pbkdf2-sha256$10$AAAA$"} {
+		if _, err := h.Verify("abcdefghij", bad); err == nil {
+			t.Errorf("Verify(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRecoveryVerifier(t *testing.T) {
+	store := NewMemoryRecoveryStore()
+	v := NewRecoveryVerifier(store, fastRecoveryConfig)
+
+	codes, err := v.Generate("alice")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if n, _ := v.Remaining("alice"); n != 10 {
+		t.Fatalf("Remaining = %d, want 10", n)
+	}
+
+	hashes, _ := store.Load("alice")
+	for i, h := range hashes {
+		if strings.Contains(h, strings.ReplaceAll(codes[i], "-", "")) {
+			t.Fatalf("store holds plain code %q", codes[i])
+		}
+	}
+
+	// Typed without separator and in upper case.
+	left, err := v.Verify("alice", strings.ToUpper(strings.ReplaceAll(codes[3], "-", "")))
+	if err != nil || left != 9 {
+		t.Fatalf("Verify = %d, %v; want 9, nil", left, err)
+	}
+	if _, err := v.Verify("alice", codes[3]); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("reused code: expected ErrInvalidRecoveryCode, got %v", err)
+	}
+	if _, err := v.Verify("bob", codes[0]); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("other user: expected ErrInvalidRecoveryCode, got %v", err)
+	}
+
+	// Regeneration invalidates the previous set.
+	fresh, err := v.Generate("alice")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, err := v.Verify("alice", codes[0]); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Errorf("old set: expected ErrInvalidRecoveryCode, got %v", err)
+	}
+	if left, err := v.Verify("alice", fresh[9]); err != nil || left != 9 {
+		t.Errorf("new set: Verify = %d, %v", left, err)
+	}
+}
+
+func TestRecoveryVerifier_Concurrent(t *testing.T) {
+	v := NewRecoveryVerifier(nil, fastRecoveryConfig)
+	codes, err := v.Generate("alice")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Verify("alice", codes[0])
+			if err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrCodeReused) && !errors.Is(err, ErrInvalidRecoveryCode) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("code accepted %d times, want 1", successes)
+	}
+}
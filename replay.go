@@ -0,0 +1,180 @@
+package otp
+
+import (
+	"sync"
+	"time"
+)
+
+// UsedCodeStore records, for each credential, the high-water mark of consumed HOTP
+// counters / TOTP time steps, so that neither the same code nor an older one can be
+// accepted again (RFC 6238 §5.2, RFC 4226 §7.2).
+//
+// Implementations must be safe for concurrent use. CompareAndSwap must be atomic,
+// e.g. an `UPDATE ... SET next = new WHERE id = ? AND next = old` in SQL, so that when
+// two callers race on the same id exactly one of them succeeds.
+type UsedCodeStore interface {
+	// Load returns the lowest counter the credential id may still use: one past the
+	// last accepted counter, or 0 if none was recorded.
+	Load(id string) (uint64, error)
+
+	// CompareAndSwap sets the next usable counter of id to new only if it currently
+	// equals old, and reports whether the swap happened. Once expires has passed, no
+	// code below new can be accepted anyway, and the store may forget id; a zero
+	// expires means the record must be kept.
+	CompareAndSwap(id string, old, new uint64, expires time.Time) (bool, error)
+}
+
+// ReplayVerifier validates TOTP and HOTP codes and rejects any code whose matched
+// counter is not above the last one accepted for the same credential: a reused code,
+// but also an older code of the skew window once a newer one has been accepted.
+type ReplayVerifier struct {
+	store UsedCodeStore
+}
+
+// NewReplayVerifier returns a ReplayVerifier backed by the given store.
+// If store is nil, a new in-memory store is used.
+func NewReplayVerifier(store UsedCodeStore) *ReplayVerifier {
+	if store == nil {
+		store = NewMemoryUsedCodeStore()
+	}
+	return &ReplayVerifier{store: store}
+}
+
+// ValidateTOTP behaves like ValidateTOTP, and additionally records the matched
+// time step for the credential id. A submission matching the same or an earlier
+// step fails with ErrCodeReused.
+//
+// The mark is needed for as long as the matched step can be accepted by the skew
+// window, i.e. until Skew periods after the end of the step, measured from t.
+func (v *ReplayVerifier) ValidateTOTP(id, secret, code string, t time.Time, param *Param) (bool, error) {
+	if param == nil {
+		_def := *DefaultTOTPParam
+		param = &_def
+	}
+
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := matchTOTP(secretBuf, code, t, param)
+	if err != nil {
+		return false, err
+	}
+
+	period := param.Period
+	if period == 0 {
+		period = 30
+	}
+	expires := res.StepEnd.Add(time.Duration(param.Skew) * time.Duration(period) * time.Second)
+
+	return v.markUsed(id, res.Counter, expires)
+}
+
+// ValidateHOTP behaves like ValidateHOTP, and additionally records the matched
+// counter for the credential id. A submission matching the same or an earlier
+// counter fails with ErrCodeReused. HOTP marks are kept without expiry.
+func (v *ReplayVerifier) ValidateHOTP(id, secret, code string, counter uint64, param *Param) (bool, error) {
+	if param == nil {
+		def := *DefaultHOTPParam
+		param = &def
+	}
+
+	secretBuf, err := DecodeSecret(secret)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := matchHOTP(secretBuf, code, counter, param)
+	if err != nil {
+		return false, err
+	}
+
+	return v.markUsed(id, res.Counter, time.Time{})
+}
+
+func (v *ReplayVerifier) markUsed(id string, counter uint64, expires time.Time) (bool, error) {
+	for {
+		next, err := v.store.Load(id)
+		if err != nil {
+			return false, err
+		}
+		if counter < next {
+			return false, ErrCodeReused
+		}
+
+		swapped, err := v.store.CompareAndSwap(id, next, counter+1, expires)
+		if err != nil {
+			return false, err
+		}
+		if swapped {
+			return true, nil
+		}
+	}
+}
+
+// usedCodeSweepInterval bounds how often MemoryUsedCodeStore scans for expired entries.
+const usedCodeSweepInterval = time.Minute
+
+type usedCodeEntry struct {
+	next    uint64
+	expires time.Time // zero means no expiry
+}
+
+// MemoryUsedCodeStore is an in-memory UsedCodeStore holding one entry per credential.
+// Expired entries are purged lazily. It is suitable for a single process;
+// use a shared store (e.g. Redis, SQL) when validating across several instances.
+type MemoryUsedCodeStore struct {
+	mu        sync.Mutex
+	entries   map[string]usedCodeEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryUsedCodeStore returns an empty in-memory UsedCodeStore.
+func NewMemoryUsedCodeStore() *MemoryUsedCodeStore {
+	return &MemoryUsedCodeStore{
+		entries: make(map[string]usedCodeEntry),
+		now:     time.Now,
+	}
+}
+
+// Load implements UsedCodeStore.
+func (s *MemoryUsedCodeStore) Load(id string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[id].next, nil
+}
+
+// CompareAndSwap implements UsedCodeStore.
+func (s *MemoryUsedCodeStore) CompareAndSwap(id string, old, new uint64, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := s.now(); now.After(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(usedCodeSweepInterval)
+	}
+
+	if s.entries[id].next != old {
+		return false, nil
+	}
+	s.entries[id] = usedCodeEntry{next: new, expires: expires}
+
+	return true, nil
+}
+
+// Len returns the number of recorded entries, including expired ones not yet purged.
+func (s *MemoryUsedCodeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func (s *MemoryUsedCodeStore) sweep(now time.Time) {
+	for id, e := range s.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(s.entries, id)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReplayVerifier_TOTP(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(59, 0)
+	param := &Param{Digits: EightDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+
+	code, err := GenerateTOTP(secret, now, param)
+	if err != nil {
+		t.Fatalf("GenerateTOTP failed: %v", err)
+	}
+
+	v := NewReplayVerifier(nil)
+
+	ok, err := v.ValidateTOTP("alice", secret, code, now, param)
+	if err != nil || !ok {
+		t.Fatalf("first validation failed: ok=%v, err=%v", ok, err)
+	}
+
+	// Same code, still inside the skew window one step later.
+	ok, err = v.ValidateTOTP("alice", secret, code, now.Add(30*time.Second), param)
+	if ok || !errors.Is(err, ErrCodeReused) {
+		t.Errorf("expected ErrCodeReused, got ok=%v, err=%v", ok, err)
+	}
+
+	// Another credential is unaffected.
+	ok, err = v.ValidateTOTP("bob", secret, code, now, param)
+	if err != nil || !ok {
+		t.Errorf("validation for another id failed: ok=%v, err=%v", ok, err)
+	}
+
+	// A wrong code is not recorded and reports ErrInvalidCode.
+	ok, err = v.ValidateTOTP("carol", secret, "00000000", now, param)
+	if ok || !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode, got ok=%v, err=%v", ok, err)
+	}
+}
+
+func TestReplayVerifier_HOTP(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	v := NewReplayVerifier(NewMemoryUsedCodeStore())
+
+	// RFC 4226 vector for counter 1, submitted while the server is at counter 0.
+	ok, err := v.ValidateHOTP("token-1", secret, "287082", 0, nil)
+	if err != nil || !ok {
+		t.Fatalf("first validation failed: ok=%v, err=%v", ok, err)
+	}
+
+	ok, err = v.ValidateHOTP("token-1", secret, "287082", 1, nil)
+	if ok || !errors.Is(err, ErrCodeReused) {
+		t.Errorf("expected ErrCodeReused, got ok=%v, err=%v", ok, err)
+	}
+}
+
+func TestReplayVerifier_Concurrent(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Now()
+	code, err := GenerateTOTP(secret, now, nil)
+	if err != nil {
+		t.Fatalf("GenerateTOTP failed: %v", err)
+	}
+
+	v := NewReplayVerifier(nil)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := v.ValidateTOTP("alice", secret, code, now, nil); ok {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 {
+		t.Errorf("expected exactly one accepted submission, got %d", accepted)
+	}
+}
+
+func TestReplayVerifier_OlderCodes(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 3, Algorithm: SHA1}
+	v := NewReplayVerifier(nil)
+
+	// Once HOTP counter 3 is accepted, the unused counter 1 is rejected.
+	code3, _ := GenerateHOTP(secret, 3, param)
+	code1, _ := GenerateHOTP(secret, 1, param)
+	if ok, err := v.ValidateHOTP("token", secret, code3, 0, param); !ok || err != nil {
+		t.Fatalf("counter 3: ok=%v, err=%v", ok, err)
+	}
+	if _, err := v.ValidateHOTP("token", secret, code1, 0, param); !errors.Is(err, ErrCodeReused) {
+		t.Errorf("counter 1: expected ErrCodeReused, got %v", err)
+	}
+	code4, _ := GenerateHOTP(secret, 4, param)
+	if ok, err := v.ValidateHOTP("token", secret, code4, 2, param); !ok || err != nil {
+		t.Errorf("counter 4: ok=%v, err=%v", ok, err)
+	}
+
+	// Once TOTP step N+1 is accepted, step N is rejected for the rest of the window.
+	now := time.Unix(1700000000, 0)
+	next, _ := GenerateTOTP(secret, now.Add(30*time.Second), param)
+	cur, _ := GenerateTOTP(secret, now, param)
+	if ok, err := v.ValidateTOTP("alice", secret, next, now, param); !ok || err != nil {
+		t.Fatalf("step N+1: ok=%v, err=%v", ok, err)
+	}
+	if _, err := v.ValidateTOTP("alice", secret, cur, now.Add(30*time.Second), param); !errors.Is(err, ErrCodeReused) {
+		t.Errorf("step N: expected ErrCodeReused, got %v", err)
+	}
+}
+
+// expiryStore records the expiry passed to CompareAndSwap.
+type expiryStore struct {
+	*MemoryUsedCodeStore
+	expires time.Time
+}
+
+func (s *expiryStore) CompareAndSwap(id string, old, new uint64, expires time.Time) (bool, error) {
+	s.expires = expires
+	return s.MemoryUsedCodeStore.CompareAndSwap(id, old, new, expires)
+}
+
+func TestReplayVerifier_Expiry(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 2, Algorithm: SHA1}
+	store := &expiryStore{MemoryUsedCodeStore: NewMemoryUsedCodeStore()}
+	v := NewReplayVerifier(store)
+
+	// The TOTP mark expires Skew periods after the end of the matched step of t.
+	at := time.Unix(1700000010, 0) // step [1699999980, 1700000010)+30
+	code, _ := GenerateTOTP(secret, at.Add(-30*time.Second), param)
+	if ok, err := v.ValidateTOTP("alice", secret, code, at, param); !ok || err != nil {
+		t.Fatalf("ValidateTOTP: ok=%v, err=%v", ok, err)
+	}
+	if want := time.Unix(1700000010+2*30, 0); !store.expires.Equal(want) {
+		t.Errorf("TOTP expires = %v, want %v", store.expires, want)
+	}
+
+	code, _ = GenerateHOTP(secret, 0, param)
+	if ok, err := v.ValidateHOTP("token", secret, code, 0, param); !ok || err != nil {
+		t.Fatalf("ValidateHOTP: ok=%v, err=%v", ok, err)
+	}
+	if !store.expires.IsZero() {
+		t.Errorf("HOTP expires = %v, want zero", store.expires)
+	}
+}
+
+func TestMemoryUsedCodeStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryUsedCodeStore()
+	s.now = func() time.Time { return now }
+
+	if ok, _ := s.CompareAndSwap("a", 0, 5, now.Add(time.Minute)); !ok {
+		t.Fatal("first swap failed")
+	}
+	if ok, _ := s.CompareAndSwap("a", 0, 6, now.Add(time.Minute)); ok {
+		t.Error("swap with stale old value succeeded")
+	}
+	if next, _ := s.Load("a"); next != 5 {
+		t.Errorf("Load = %d, want 5", next)
+	}
+
+	// One entry per id, however many counters are consumed.
+	for c := uint64(0); c < 100; c++ {
+		_, _ = s.CompareAndSwap("b", c, c+1, time.Time{})
+	}
+	if n := s.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+
+	// Expired entries are purged by a later sweep; entries without expiry are kept.
+	now = now.Add(2 * time.Minute)
+	_, _ = s.CompareAndSwap("c", 0, 1, now.Add(time.Minute))
+	if next, _ := s.Load("a"); next != 0 {
+		t.Errorf("expired entry: Load = %d, want 0", next)
+	}
+	if next, _ := s.Load("b"); next != 100 {
+		t.Errorf("entry without expiry: Load = %d, want 100", next)
+	}
+}
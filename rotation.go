@@ -0,0 +1,142 @@
+package otp
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// SecretVersion is one version of a credential's TOTP secret.
+type SecretVersion struct {
+	// Version identifies the secret, e.g. a counter incremented on every rotation.
+	Version uint32
+
+	// Secret is the base32-encoded shared secret.
+	Secret string
+
+	// Param configures codes of this version. If nil, DefaultTOTPParam is used. It is
+	// copied by NewRotatingValidator.
+	Param *Param
+
+	// NotBefore is the time from which the version is accepted. Zero means always.
+	NotBefore time.Time
+
+	// NotAfter is the time from which the version is no longer accepted. Zero means never.
+	NotAfter time.Time
+}
+
+// activeAt reports whether v is accepted at t.
+func (v *SecretVersion) activeAt(t time.Time) bool {
+	return (v.NotBefore.IsZero() || !t.Before(v.NotBefore)) && (v.NotAfter.IsZero() || t.Before(v.NotAfter))
+}
+
+// RotationResult reports which secret version accepted a code.
+type RotationResult struct {
+	// Version is the Version of the matched secret.
+	Version uint32
+
+	// Latest reports whether the matched secret is the newest version. When it is not,
+	// the user still has the old secret and the newer one has not been confirmed yet.
+	Latest bool
+
+	// ValidationResult reports which step of the skew window matched.
+	ValidationResult
+}
+
+// RotatingValidator validates TOTP codes while a credential's secret is being rotated:
+// during a grace period both the old and the new secret are accepted, and each result
+// reports which version matched, so the old one can be retired once the new one is
+// in use.
+type RotatingValidator struct {
+	versions []SecretVersion
+	secrets  [][]byte
+}
+
+// NewRotatingValidator returns a RotatingValidator for versions, ordered from the oldest
+// to the newest. Versions must be distinct, and every secret must decode.
+func NewRotatingValidator(versions []SecretVersion) (*RotatingValidator, error) {
+	if len(versions) == 0 {
+		return nil, ErrSecretRequired
+	}
+
+	v := &RotatingValidator{
+		versions: make([]SecretVersion, len(versions)),
+		secrets:  make([][]byte, len(versions)),
+	}
+	seen := make(map[uint32]bool, len(versions))
+	for i, sv := range versions {
+		if seen[sv.Version] {
+			return nil, fmt.Errorf("duplicate secret version %d", sv.Version)
+		}
+		seen[sv.Version] = true
+
+		secret, err := DecodeSecret(sv.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret version %d: %w", sv.Version, err)
+		}
+		// Copy the Param so that later changes by the caller do not affect validation.
+		param := DefaultTOTPParam
+		if sv.Param != nil {
+			param = sv.Param
+		}
+		p := *param
+		sv.Param = &p
+
+		v.versions[i] = sv
+		v.secrets[i] = secret
+	}
+
+	return v, nil
+}
+
+// ValidateTOTP checks code against every version active at t, from the newest to the
+// oldest, and reports the first that accepts it. If t is zero, each version's Clock is
+// used. It fails with ErrNoActiveSecret if no version is active at t.
+func (v *RotatingValidator) ValidateTOTP(code string, t time.Time) (RotationResult, error) {
+	active := false
+	for i := len(v.versions) - 1; i >= 0; i-- {
+		sv := &v.versions[i]
+		at := sv.Param.timeOrNow(t)
+		if !sv.activeAt(at) {
+			continue
+		}
+		active = true
+
+		res, err := matchTOTP(v.secrets[i], code, at, sv.Param)
+		if err == nil {
+			return RotationResult{
+				Version:          sv.Version,
+				Latest:           i == len(v.versions)-1,
+				ValidationResult: res,
+			}, nil
+		}
+	}
+
+	if !active {
+		return RotationResult{}, ErrNoActiveSecret
+	}
+	return RotationResult{}, ErrInvalidCode
+}
+
+// Latest returns the newest secret version.
+func (v *RotatingValidator) Latest() SecretVersion {
+	return v.versions[len(v.versions)-1]
+}
+
+// TOTPURL returns an otpauth:// URL, built with GenerateTOTPURL, that enrolls the newest
+// secret version. Issuer and AccountName are taken from param; the secret, period,
+// digits, algorithm and encoder from the version.
+func (v *RotatingValidator) TOTPURL(param URLParam) (*url.URL, error) {
+	latest := v.Latest()
+
+	param.Secret = latest.Secret
+	param.Period = latest.Param.Period
+	param.Digits = latest.Param.Digits
+	param.Algorithm = latest.Param.Algorithm
+	param.Encoder = ""
+	if enc := latest.Param.Encoder; enc != nil && enc != DecimalEncoder {
+		param.Encoder = enc.String()
+	}
+
+	return GenerateTOTPURL(param)
+}
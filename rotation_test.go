@@ -0,0 +1,133 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotatingValidator(t *testing.T) {
+	const (
+		oldSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		newSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+	)
+	rotated := time.Unix(1700000000, 0)
+	graceEnd := rotated.Add(24 * time.Hour)
+	param := &Param{Digits: SixDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+
+	v, err := NewRotatingValidator([]SecretVersion{
+		{Version: 1, Secret: oldSecret, Param: param, NotAfter: graceEnd},
+		{Version: 2, Secret: newSecret, Param: param, NotBefore: rotated},
+	})
+	if err != nil {
+		t.Fatalf("NewRotatingValidator failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		secret      string
+		at          time.Time
+		wantVersion uint32
+		wantLatest  bool
+		wantErr     error
+	}{
+		{name: "old secret before rotation", secret: oldSecret, at: rotated.Add(-time.Hour), wantVersion: 1},
+		{name: "new secret before rotation", secret: newSecret, at: rotated.Add(-time.Hour), wantErr: ErrInvalidCode},
+		{name: "old secret in grace period", secret: oldSecret, at: rotated.Add(time.Hour), wantVersion: 1},
+		{name: "new secret in grace period", secret: newSecret, at: rotated.Add(time.Hour), wantVersion: 2, wantLatest: true},
+		{name: "old secret after grace period", secret: oldSecret, at: graceEnd, wantErr: ErrInvalidCode},
+		{name: "new secret after grace period", secret: newSecret, at: graceEnd, wantVersion: 2, wantLatest: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := GenerateTOTP(tt.secret, tt.at.Add(-30*time.Second), param)
+			if err != nil {
+				t.Fatalf("GenerateTOTP failed: %v", err)
+			}
+
+			res, err := v.ValidateTOTP(code, tt.at)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if res.Version != tt.wantVersion || res.Latest != tt.wantLatest || res.Offset != -1 {
+				t.Errorf("got %+v, want version %d, latest %v, offset -1", res, tt.wantVersion, tt.wantLatest)
+			}
+		})
+	}
+
+	// Nothing is active once the only remaining version has expired.
+	expired, _ := NewRotatingValidator([]SecretVersion{{Version: 1, Secret: oldSecret, NotAfter: rotated}})
+	if _, err := expired.ValidateTOTP("123456", rotated); !errors.Is(err, ErrNoActiveSecret) {
+		t.Errorf("expected ErrNoActiveSecret, got %v", err)
+	}
+
+	// A zero time uses the version's clock.
+	clocked := &Param{Digits: SixDigits, Period: 30, Algorithm: SHA1, Clock: func() time.Time { return rotated.Add(time.Hour) }}
+	cv, _ := NewRotatingValidator([]SecretVersion{{Version: 7, Secret: newSecret, Param: clocked, NotBefore: rotated}})
+	code, _ := GenerateTOTP(newSecret, rotated.Add(time.Hour), clocked)
+	if res, err := cv.ValidateTOTP(code, time.Time{}); err != nil || res.Version != 7 {
+		t.Errorf("with clock: got %+v, %v", res, err)
+	}
+}
+
+func TestRotatingValidator_Errors(t *testing.T) {
+	if _, err := NewRotatingValidator(nil); !errors.Is(err, ErrSecretRequired) {
+		t.Errorf("expected ErrSecretRequired, got %v", err)
+	}
+	if _, err := NewRotatingValidator([]SecretVersion{
+		{Version: 1, Secret: "GEZDGNBVGY3TQOJQ"},
+		{Version: 1, Secret: "JBSWY3DPEHPK3PXP"},
+	}); err == nil {
+		t.Error("expected error for duplicate versions")
+	}
+	if _, err := NewRotatingValidator([]SecretVersion{{Version: 1, Secret: "not base32!"}}); err == nil {
+		t.Error("expected error for invalid secret")
+	}
+}
+
+func TestRotatingValidator_CopiesParam(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	at := time.Unix(1700000000, 0)
+	param := &Param{Digits: SixDigits, Period: 30, Algorithm: SHA1}
+
+	v, err := NewRotatingValidator([]SecretVersion{{Version: 1, Secret: secret, Param: param}})
+	if err != nil {
+		t.Fatalf("NewRotatingValidator failed: %v", err)
+	}
+	code, _ := GenerateTOTP(secret, at, param)
+
+	// Changing the caller's Param afterwards does not affect the validator.
+	param.Digits = EightDigits
+	param.Algorithm = SHA256
+	if res, err := v.ValidateTOTP(code, at); err != nil || res.Version != 1 {
+		t.Errorf("after mutating Param: got %+v, %v", res, err)
+	}
+}
+
+func TestRotatingValidator_TOTPURL(t *testing.T) {
+	v, err := NewRotatingValidator([]SecretVersion{
+		{Version: 1, Secret: "GEZDGNBVGY3TQOJQ"},
+		{Version: 2, Secret: "JBSWY3DPEHPK3PXP", Param: &Param{Digits: EightDigits, Period: 60, Algorithm: SHA256, Encoder: SteamEncoder}},
+	})
+	if err != nil {
+		t.Fatalf("NewRotatingValidator failed: %v", err)
+	}
+
+	u, err := v.TOTPURL(URLParam{Issuer: "Example", AccountName: "alice@example.com", Secret: "ignored"})
+	if err != nil {
+		t.Fatalf("TOTPURL failed: %v", err)
+	}
+
+	parsed, err := ParseOTPAuthURL(u)
+	if err != nil {
+		t.Fatalf("ParseOTPAuthURL failed: %v", err)
+	}
+	if parsed.Secret != "JBSWY3DPEHPK3PXP" || parsed.Period != 60 || parsed.Digits != EightDigits ||
+		parsed.Algorithm != SHA256 || parsed.Encoder != SteamEncoderName || parsed.Issuer != "Example" {
+		t.Errorf("unexpected URL parameters %+v", parsed)
+	}
+}
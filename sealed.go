@@ -0,0 +1,372 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// sealedSecretVersion is the current SealedSecret format version.
+const sealedSecretVersion = 1
+
+// dekSize is the size of the random per-secret data-encryption key (AES-256).
+const dekSize = 32
+
+// SealedSecret is an OTP secret encrypted at rest with envelope encryption.
+//
+// The raw secret is encrypted with a random per-secret data-encryption key (DEK) using
+// AES-GCM, and the DEK is in turn wrapped by a key-encryption key (KEK) held in a Keyring
+// and identified by KeyID. Rotating the KEK therefore only requires re-wrapping the small
+// DEK (see Keyring.Rewrap); rows sealed under an older KEK keep working as long as that
+// KEK stays in the keyring.
+//
+// SealedSecret implements sql.Scanner and driver.Valuer, so it can be stored directly
+// in a TEXT or BLOB column, as well as encoding.TextMarshaler for JSON and config files.
+// Keyring.NewKey turns it into a Key for generation and validation without exposing the
+// plaintext secret to the caller.
+type SealedSecret struct {
+	// Version is the format version of the sealed secret.
+	Version uint8
+
+	// KeyID identifies the KEK that wrapped the DEK.
+	KeyID string
+
+	// WrappedKey is the DEK encrypted by the KEK (nonce || ciphertext).
+	WrappedKey []byte
+
+	// Ciphertext is the secret encrypted by the DEK (nonce || ciphertext).
+	Ciphertext []byte
+}
+
+// IsZero reports whether s holds no sealed secret, e.g. after scanning a NULL column.
+func (s SealedSecret) IsZero() bool {
+	return s.Version == 0 && s.KeyID == "" && len(s.WrappedKey) == 0 && len(s.Ciphertext) == 0
+}
+
+// MarshalBinary encodes s as
+// version(1) || len(KeyID)(1) || KeyID || len(WrappedKey)(2) || WrappedKey || Ciphertext.
+func (s SealedSecret) MarshalBinary() ([]byte, error) {
+	if s.Version != sealedSecretVersion || len(s.KeyID) > 0xFF || len(s.WrappedKey) > 0xFFFF {
+		return nil, ErrInvalidSealedSecret
+	}
+
+	out := make([]byte, 0, 4+len(s.KeyID)+len(s.WrappedKey)+len(s.Ciphertext))
+	out = append(out, s.Version, byte(len(s.KeyID)))
+	out = append(out, s.KeyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(s.WrappedKey)))
+	out = append(out, s.WrappedKey...)
+	out = append(out, s.Ciphertext...)
+
+	return out, nil
+}
+
+// UnmarshalBinary decodes the format produced by MarshalBinary.
+func (s *SealedSecret) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != sealedSecretVersion {
+		return ErrInvalidSealedSecret
+	}
+
+	idLen := int(data[1])
+	data = data[2:]
+	if len(data) < idLen+2 {
+		return ErrInvalidSealedSecret
+	}
+	keyID := string(data[:idLen])
+	data = data[idLen:]
+
+	wrappedLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < wrappedLen {
+		return ErrInvalidSealedSecret
+	}
+
+	*s = SealedSecret{
+		Version:    sealedSecretVersion,
+		KeyID:      keyID,
+		WrappedKey: append([]byte(nil), data[:wrappedLen]...),
+		Ciphertext: append([]byte(nil), data[wrappedLen:]...),
+	}
+
+	return nil
+}
+
+// MarshalText encodes s as unpadded base64 of MarshalBinary.
+func (s SealedSecret) MarshalText() ([]byte, error) {
+	b, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, base64.RawStdEncoding.EncodedLen(len(b)))
+	base64.RawStdEncoding.Encode(out, b)
+	return out, nil
+}
+
+// UnmarshalText decodes the format produced by MarshalText.
+func (s *SealedSecret) UnmarshalText(text []byte) error {
+	b, err := base64.RawStdEncoding.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSealedSecret, err)
+	}
+	return s.UnmarshalBinary(b)
+}
+
+// String returns the text encoding of s, or an empty string if it cannot be encoded.
+func (s SealedSecret) String() string {
+	b, err := s.MarshalText()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Value implements driver.Valuer. A zero SealedSecret is stored as NULL.
+func (s SealedSecret) Value() (driver.Value, error) {
+	if s.IsZero() {
+		return nil, nil
+	}
+	b, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner. It accepts the text encoding as string or []byte,
+// and leaves s zero for NULL.
+func (s *SealedSecret) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*s = SealedSecret{}
+		return nil
+	case string:
+		return s.UnmarshalText([]byte(v))
+	case []byte:
+		return s.UnmarshalText(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidSealedSecret, src)
+	}
+}
+
+// Keyring holds the key-encryption keys used to seal and open secrets.
+// New secrets are always sealed with the primary key; any key in the ring can open.
+// A Keyring is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	primary string
+}
+
+// NewKeyring returns a Keyring whose primary KEK is kek, identified by id.
+// kek must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256).
+func NewKeyring(id string, kek []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	if err := k.Add(id, kek); err != nil {
+		return nil, err
+	}
+	k.primary = id
+	return k, nil
+}
+
+// Add registers an additional KEK under id, without making it primary.
+func (k *Keyring) Add(id string, kek []byte) error {
+	if id == "" || len(id) > 0xFF {
+		return fmt.Errorf("invalid key id %q", id)
+	}
+
+	aead, err := newGCM(kek)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[id] = aead
+
+	return nil
+}
+
+// SetPrimary makes the KEK registered under id the one used by Seal and Rewrap.
+func (k *Keyring) SetPrimary(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return ErrUnknownKeyID
+	}
+	k.primary = id
+
+	return nil
+}
+
+// Primary returns the id of the primary KEK.
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Seal decodes the base32 secret and encrypts it under a fresh DEK wrapped by the primary KEK.
+//
+// context is optional associated data (e.g. the user or credential id) that is
+// authenticated but not stored; the same context must be given to Open. Binding the
+// secret to its owner prevents a sealed value from being copied to another row.
+func (k *Keyring) Seal(secret string, context []byte) (SealedSecret, error) {
+	raw, err := DecodeSecret(secret)
+	if err != nil {
+		return SealedSecret{}, err
+	}
+	return k.SealBytes(raw, context)
+}
+
+// SealBytes is like Seal but takes the raw, already decoded secret.
+func (k *Keyring) SealBytes(secret, context []byte) (SealedSecret, error) {
+	id, kek := k.primaryKey()
+
+	dek := make([]byte, dekSize)
+	if _, err := rand.Read(dek); err != nil {
+		return SealedSecret{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrapped, err := sealGCM(kek, dek, wrapAAD(id))
+	if err != nil {
+		return SealedSecret{}, err
+	}
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return SealedSecret{}, err
+	}
+	ciphertext, err := sealGCM(aead, secret, context)
+	if err != nil {
+		return SealedSecret{}, err
+	}
+
+	return SealedSecret{
+		Version:    sealedSecretVersion,
+		KeyID:      id,
+		WrappedKey: wrapped,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Open decrypts s and returns the secret base32-encoded without padding, ready to be
+// passed to GenerateTOTP, ValidateHOTP, GenerateOCRA and the other secret-taking APIs.
+func (k *Keyring) Open(s SealedSecret, context []byte) (string, error) {
+	raw, err := k.OpenBytes(s, context)
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
+
+// OpenBytes decrypts s and returns the raw secret, i.e. what DecodeSecret would return.
+func (k *Keyring) OpenBytes(s SealedSecret, context []byte) ([]byte, error) {
+	dek, err := k.unwrap(s)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	return openGCM(aead, s.Ciphertext, context)
+}
+
+// NewKey opens s and returns a Key for it, like NewKeyFromBytes, so that a sealed secret
+// can be used to generate and validate codes without the plaintext passing through the
+// caller. The decrypted secret is wiped once the Key is built.
+func (k *Keyring) NewKey(s SealedSecret, context []byte, param *Param) (*Key, error) {
+	raw, err := k.OpenBytes(s, context)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(raw)
+
+	return NewKeyFromBytes(raw, param)
+}
+
+// Rewrap re-encrypts the DEK of s under the current primary KEK, leaving the secret
+// ciphertext untouched. Use it to migrate rows lazily after SetPrimary; once no row
+// references an old KEK it can be dropped from the keyring.
+func (k *Keyring) Rewrap(s SealedSecret) (SealedSecret, error) {
+	dek, err := k.unwrap(s)
+	if err != nil {
+		return SealedSecret{}, err
+	}
+
+	id, kek := k.primaryKey()
+	wrapped, err := sealGCM(kek, dek, wrapAAD(id))
+	if err != nil {
+		return SealedSecret{}, err
+	}
+
+	return SealedSecret{
+		Version:    sealedSecretVersion,
+		KeyID:      id,
+		WrappedKey: wrapped,
+		Ciphertext: append([]byte(nil), s.Ciphertext...),
+	}, nil
+}
+
+func (k *Keyring) unwrap(s SealedSecret) ([]byte, error) {
+	if s.Version != sealedSecretVersion {
+		return nil, ErrInvalidSealedSecret
+	}
+
+	k.mu.RLock()
+	kek, ok := k.keys[s.KeyID]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	return openGCM(kek, s.WrappedKey, wrapAAD(s.KeyID))
+}
+
+func (k *Keyring) primaryKey() (string, cipher.AEAD) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary, k.keys[k.primary]
+}
+
+// wrapAAD binds a wrapped DEK to the format version and the id of its KEK.
+func wrapAAD(keyID string) []byte {
+	return append([]byte{sealedSecretVersion}, keyID...)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealGCM(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func openGCM(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidSealedSecret
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrInvalidSealedSecret
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,216 @@
+package otp
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	_ sql.Scanner   = (*SealedSecret)(nil)
+	_ driver.Valuer = SealedSecret{}
+)
+
+func TestKeyring_SealOpen(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	kr, err := NewKeyring("kek-1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+
+	sealed, err := kr.Seal(secret, []byte("user-42"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("12345678901234567890")) {
+		t.Fatal("ciphertext contains the plaintext secret")
+	}
+
+	opened, err := kr.Open(sealed, []byte("user-42"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if opened != secret {
+		t.Errorf("Open() = %q, want %q", opened, secret)
+	}
+
+	// The opened secret plugs directly into the generation APIs.
+	now := time.Now()
+	want, _ := GenerateTOTP(secret, now, nil)
+	got, err := GenerateTOTP(opened, now, nil)
+	if err != nil || got != want {
+		t.Errorf("GenerateTOTP with opened secret = %q, %v; want %q", got, err, want)
+	}
+
+	if _, err := kr.Open(sealed, []byte("user-43")); !errors.Is(err, ErrInvalidSealedSecret) {
+		t.Errorf("expected ErrInvalidSealedSecret for wrong context, got %v", err)
+	}
+
+	tampered := sealed
+	tampered.Ciphertext = append([]byte(nil), sealed.Ciphertext...)
+	tampered.Ciphertext[len(tampered.Ciphertext)-1] ^= 1
+	if _, err := kr.Open(tampered, []byte("user-42")); !errors.Is(err, ErrInvalidSealedSecret) {
+		t.Errorf("expected ErrInvalidSealedSecret for tampered ciphertext, got %v", err)
+	}
+}
+
+func TestKeyring_NewKey(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	param := &Param{Digits: EightDigits, Period: 30, Skew: 1, Algorithm: SHA1}
+	kr, _ := NewKeyring("kek-1", bytes.Repeat([]byte{1}, 32))
+	sealed, err := kr.Seal(secret, []byte("user-42"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	key, err := kr.NewKey(sealed, []byte("user-42"), param)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	now := time.Unix(1111111109, 0)
+	code, err := key.GenerateTOTP(now)
+	if err != nil || code != "07081804" {
+		t.Errorf("GenerateTOTP = %s, %v; want 07081804", code, err)
+	}
+	if res, err := key.ValidateTOTP(code, now); err != nil || res.Offset != 0 {
+		t.Errorf("ValidateTOTP = %+v, %v", res, err)
+	}
+
+	if _, err := kr.NewKey(sealed, []byte("user-43"), param); !errors.Is(err, ErrInvalidSealedSecret) {
+		t.Errorf("expected ErrInvalidSealedSecret for wrong context, got %v", err)
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	secret, err := RandomSecret(SHA256)
+	if err != nil {
+		t.Fatalf("RandomSecret failed: %v", err)
+	}
+
+	kr, err := NewKeyring("kek-1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	old, err := kr.Seal(secret, nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := kr.Add("kek-2", bytes.Repeat([]byte{2}, 16)); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := kr.SetPrimary("kek-2"); err != nil {
+		t.Fatalf("SetPrimary failed: %v", err)
+	}
+	if err := kr.SetPrimary("missing"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("expected ErrUnknownKeyID, got %v", err)
+	}
+
+	// Rows sealed under the old KEK still open.
+	if got, err := kr.Open(old, nil); err != nil || got != secret {
+		t.Fatalf("Open(old) = %q, %v", got, err)
+	}
+
+	rewrapped, err := kr.Rewrap(old)
+	if err != nil {
+		t.Fatalf("Rewrap failed: %v", err)
+	}
+	if rewrapped.KeyID != "kek-2" {
+		t.Errorf("KeyID = %q, want kek-2", rewrapped.KeyID)
+	}
+	if !bytes.Equal(rewrapped.Ciphertext, old.Ciphertext) {
+		t.Error("Rewrap must not re-encrypt the secret ciphertext")
+	}
+
+	// After dropping the old KEK only rewrapped rows open.
+	fresh, err := NewKeyring("kek-2", bytes.Repeat([]byte{2}, 16))
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	if got, err := fresh.Open(rewrapped, nil); err != nil || got != secret {
+		t.Errorf("Open(rewrapped) = %q, %v", got, err)
+	}
+	if _, err := fresh.Open(old, nil); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("expected ErrUnknownKeyID, got %v", err)
+	}
+}
+
+func TestSealedSecret_Encoding(t *testing.T) {
+	kr, err := NewKeyring("kek-1", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	sealed, err := kr.Seal("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	t.Run("sql", func(t *testing.T) {
+		v, err := sealed.Value()
+		if err != nil {
+			t.Fatalf("Value failed: %v", err)
+		}
+
+		for _, src := range []any{v, []byte(v.(string))} {
+			var got SealedSecret
+			if err := got.Scan(src); err != nil {
+				t.Fatalf("Scan(%T) failed: %v", src, err)
+			}
+			if got.KeyID != sealed.KeyID || !bytes.Equal(got.WrappedKey, sealed.WrappedKey) || !bytes.Equal(got.Ciphertext, sealed.Ciphertext) {
+				t.Errorf("Scan(%T) round-trip mismatch", src)
+			}
+		}
+
+		var null SealedSecret
+		if err := null.Scan(nil); err != nil || !null.IsZero() {
+			t.Errorf("Scan(nil) = %v, zero=%v", err, null.IsZero())
+		}
+		if v, err := null.Value(); v != nil || err != nil {
+			t.Errorf("zero Value() = %v, %v; want nil", v, err)
+		}
+		if err := null.Scan(42); !errors.Is(err, ErrInvalidSealedSecret) {
+			t.Errorf("expected ErrInvalidSealedSecret, got %v", err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(struct{ Secret SealedSecret }{sealed})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var out struct{ Secret SealedSecret }
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if out.Secret.String() != sealed.String() {
+			t.Errorf("JSON round-trip mismatch")
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		for _, version := range []uint8{0, 2} {
+			bad := sealed
+			bad.Version = version
+			if _, err := bad.MarshalBinary(); !errors.Is(err, ErrInvalidSealedSecret) {
+				t.Errorf("MarshalBinary with version %d = %v, want ErrInvalidSealedSecret", version, err)
+			}
+			if _, err := bad.Value(); !errors.Is(err, ErrInvalidSealedSecret) {
+				t.Errorf("Value with version %d = %v, want ErrInvalidSealedSecret", version, err)
+			}
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, in := range [][]byte{nil, {0}, {1, 5, 'a'}, {2, 0, 0, 0}, {1, 0, 0, 9}} {
+			var s SealedSecret
+			if err := s.UnmarshalBinary(in); !errors.Is(err, ErrInvalidSealedSecret) {
+				t.Errorf("UnmarshalBinary(%v) = %v, want ErrInvalidSealedSecret", in, err)
+			}
+		}
+	})
+}
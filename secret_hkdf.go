@@ -0,0 +1,58 @@
+package otp
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+)
+
+// MinMasterKeySize is the minimum master key length accepted by DeriveSecret.
+const MinMasterKeySize = 16
+
+// hkdfSecretLabel domain-separates secrets derived by DeriveSecret from any other
+// use of the same master key.
+const hkdfSecretLabel = "github.com/ja7ad/otp secret v1"
+
+// DeriveSecret deterministically derives a base32-encoded secret for the given algorithm
+// from a master key, a user or credential identifier and a version number, using
+// HKDF-SHA256 (RFC 5869). The secret has the same length as one from RandomSecret.
+//
+// This lets large deployments store only the master key and a small version per user
+// instead of millions of random seeds. To re-enroll a single user (e.g. after a lost
+// device), increment that user's version: the new secret is unrelated to the old one,
+// while every other user's secret stays unchanged.
+//
+// The master key must be at least MinMasterKeySize bytes of uniformly random data and
+// must be protected like every secret derived from it.
+func DeriveSecret(masterKey []byte, id string, version uint32, algo Algorithm) (string, error) {
+	if len(masterKey) < MinMasterKeySize {
+		return "", ErrMasterKeyTooShort
+	}
+
+	size, err := secretSize(algo)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := hkdf.Key(sha256.New, masterKey, nil, hkdfSecretInfo(id, version, algo), size)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive secret: %w", err)
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
+}
+
+// hkdfSecretInfo encodes label || algorithm || version || id unambiguously,
+// so that different inputs can never produce the same info string.
+func hkdfSecretInfo(id string, version uint32, algo Algorithm) string {
+	info := make([]byte, 0, len(hkdfSecretLabel)+len(id)+16)
+	info = append(info, hkdfSecretLabel...)
+	info = append(info, 0)
+	info = append(info, algo.String()...)
+	info = append(info, 0)
+	info = binary.BigEndian.AppendUint32(info, version)
+	info = append(info, id...)
+	return string(info)
+}
@@ -0,0 +1,94 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestDeriveSecret(t *testing.T) {
+	master := bytes.Repeat([]byte{0x42}, 32)
+
+	tests := []struct {
+		name      string
+		algo      Algorithm
+		wantBytes int
+	}{
+		{"SHA1", SHA1, 20},
+		{"SHA256", SHA256, 32},
+		{"SHA512", SHA512, 64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := DeriveSecret(master, "alice", 1, tt.algo)
+			if err != nil {
+				t.Fatalf("DeriveSecret failed: %v", err)
+			}
+
+			decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+			if err != nil {
+				t.Fatalf("failed to decode base32: %v", err)
+			}
+			if len(decoded) != tt.wantBytes {
+				t.Errorf("decoded length = %d, want %d", len(decoded), tt.wantBytes)
+			}
+
+			again, _ := DeriveSecret(master, "alice", 1, tt.algo)
+			if again != secret {
+				t.Errorf("derivation is not deterministic")
+			}
+
+			if _, err := GenerateTOTP(secret, time.Now(), &Param{Digits: SixDigits, Period: 30, Algorithm: tt.algo}); err != nil {
+				t.Errorf("GenerateTOTP with derived secret failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeriveSecret_Separation(t *testing.T) {
+	master := bytes.Repeat([]byte{0x42}, 32)
+
+	base, err := DeriveSecret(master, "alice", 1, SHA1)
+	if err != nil {
+		t.Fatalf("DeriveSecret failed: %v", err)
+	}
+
+	variants := map[string]func() (string, error){
+		"other user": func() (string, error) {
+			return DeriveSecret(master, "bob", 1, SHA1)
+		},
+		"bumped version": func() (string, error) {
+			return DeriveSecret(master, "alice", 2, SHA1)
+		},
+		"other master key": func() (string, error) {
+			return DeriveSecret(bytes.Repeat([]byte{0x43}, 32), "alice", 1, SHA1)
+		},
+		"other algorithm": func() (string, error) {
+			s, err := DeriveSecret(master, "alice", 1, SHA256)
+			return s[:len(base)], err
+		},
+	}
+
+	for name, fn := range variants {
+		t.Run(name, func(t *testing.T) {
+			got, err := fn()
+			if err != nil {
+				t.Fatalf("DeriveSecret failed: %v", err)
+			}
+			if got == base {
+				t.Errorf("expected a different secret")
+			}
+		})
+	}
+}
+
+func TestDeriveSecret_Errors(t *testing.T) {
+	if _, err := DeriveSecret(make([]byte, MinMasterKeySize-1), "alice", 1, SHA1); err != ErrMasterKeyTooShort {
+		t.Errorf("expected ErrMasterKeyTooShort, got %v", err)
+	}
+	if _, err := DeriveSecret(make([]byte, 32), "alice", 1, Algorithm(99)); err != ErrUnsupportedAlgorithm {
+		t.Errorf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package otp
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SKeyHash defines the hash function of an RFC 2289 one-time password chain.
+type SKeyHash uint8
+
+const (
+	// SKeyMD5 is the "otp-md5" hash, the most widely deployed S/KEY variant.
+	SKeyMD5 SKeyHash = iota
+
+	// SKeySHA1 is the "otp-sha1" hash.
+	SKeySHA1
+)
+
+// skeyShortWords is the number of one to three letter words at the start of skeyWords.
+const skeyShortWords = 571
+
+func (h SKeyHash) String() string {
+	switch h {
+	case SKeyMD5:
+		return "md5"
+	case SKeySHA1:
+		return "sha1"
+	default:
+		return ""
+	}
+}
+
+// SKeyHashFromStr returns the SKeyHash for "md5" or "sha1", with or without the "otp-" prefix.
+func SKeyHashFromStr(s string) (SKeyHash, error) {
+	switch strings.TrimPrefix(strings.ToLower(s), "otp-") {
+	case "md5":
+		return SKeyMD5, nil
+	case "sha1":
+		return SKeySHA1, nil
+	default:
+		return 0, ErrUnsupportedAlgorithm
+	}
+}
+
+// SKeyOTP is a 64-bit RFC 2289 one-time password.
+type SKeyOTP [8]byte
+
+// Hex returns the OTP as 16 upper-case hexadecimal characters.
+func (o SKeyOTP) Hex() string {
+	return strings.ToUpper(hex.EncodeToString(o[:]))
+}
+
+// Words returns the OTP in the six-word format of RFC 2289 §6, e.g. "INCH SEA ANNE LONG AHEM TOUR".
+func (o SKeyOTP) Words() string {
+	v := binary.BigEndian.Uint64(o[:])
+	parity := skeyParity(v)
+
+	var words [6]string
+	for i := range words {
+		// 66 bits = 64 bits of OTP followed by the 2-bit checksum, 11 bits per word.
+		shift := 66 - 11*(i+1)
+		var idx uint64
+		if shift >= 2 {
+			idx = v >> (shift - 2)
+		} else {
+			idx = v<<(2-shift) | parity
+		}
+		words[i] = skeyWords[idx&0x7FF]
+	}
+
+	return strings.Join(words[:], " ")
+}
+
+func (o SKeyOTP) String() string {
+	return o.Words()
+}
+
+// MarshalText encodes the OTP in hexadecimal.
+func (o SKeyOTP) MarshalText() ([]byte, error) {
+	return []byte(o.Hex()), nil
+}
+
+// UnmarshalText accepts any format understood by ParseSKeyOTP.
+func (o *SKeyOTP) UnmarshalText(text []byte) error {
+	otp, err := ParseSKeyOTP(string(text))
+	if err != nil {
+		return err
+	}
+	*o = otp
+	return nil
+}
+
+// ParseSKeyOTP parses a response in six-word or hexadecimal format. Words are matched
+// case-insensitively and their checksum is verified; hexadecimal may contain white space.
+// The RFC 2243 "word:" and "hex:" prefixes are accepted.
+func ParseSKeyOTP(s string) (SKeyOTP, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+
+	switch {
+	case strings.HasPrefix(lower, "hex:"):
+		return parseSKeyHex(s[4:])
+	case strings.HasPrefix(lower, "word:"):
+		return parseSKeyWords(s[5:])
+	}
+
+	if otp, err := parseSKeyHex(s); err == nil {
+		return otp, nil
+	}
+	return parseSKeyWords(s)
+}
+
+func parseSKeyHex(s string) (SKeyOTP, error) {
+	s = strings.Join(strings.Fields(s), "")
+	var otp SKeyOTP
+	if len(s) != 16 {
+		return otp, ErrInvalidSKeyResponse
+	}
+	if _, err := hex.Decode(otp[:], []byte(s)); err != nil {
+		return otp, ErrInvalidSKeyResponse
+	}
+	return otp, nil
+}
+
+func parseSKeyWords(s string) (SKeyOTP, error) {
+	fields := strings.Fields(strings.ToUpper(s))
+	if len(fields) != 6 {
+		return SKeyOTP{}, ErrInvalidSKeyResponse
+	}
+
+	var v uint64
+	var parity uint64
+	for i, w := range fields {
+		idx, ok := skeyWordIndex(w)
+		if !ok {
+			return SKeyOTP{}, fmt.Errorf("%w: unknown word %q", ErrInvalidSKeyResponse, w)
+		}
+		if i < 5 {
+			v = v<<11 | idx
+		} else {
+			// The last word carries the 9 low bits of the OTP and the 2-bit checksum.
+			v = v<<9 | idx>>2
+			parity = idx & 0x3
+		}
+	}
+
+	if skeyParity(v) != parity {
+		return SKeyOTP{}, fmt.Errorf("%w: checksum mismatch", ErrInvalidSKeyResponse)
+	}
+
+	var otp SKeyOTP
+	binary.BigEndian.PutUint64(otp[:], v)
+	return otp, nil
+}
+
+// skeyWordIndex looks a word up in the sorted short or four-letter part of the dictionary.
+func skeyWordIndex(w string) (uint64, bool) {
+	words, base := skeyWords[:skeyShortWords], 0
+	if len(w) == 4 {
+		words, base = skeyWords[skeyShortWords:], skeyShortWords
+	}
+
+	i := sort.SearchStrings(words, w)
+	if i < len(words) && words[i] == w {
+		return uint64(base + i), true
+	}
+	return 0, false
+}
+
+// skeyParity returns the RFC 2289 checksum: the sum of all 2-bit pairs of v, modulo 4.
+func skeyParity(v uint64) uint64 {
+	var p uint64
+	for i := 0; i < 64; i += 2 {
+		p += (v >> i) & 0x3
+	}
+	return p & 0x3
+}
+
+// SKeyChallenge is an RFC 2289 challenge such as "otp-sha1 499 ke1234".
+type SKeyChallenge struct {
+	Hash     SKeyHash
+	Sequence uint
+	Seed     string
+}
+
+// ParseSKeyChallenge parses a challenge of the form "otp-<hash> <sequence> <seed>".
+// Trailing tokens (e.g. the RFC 2243 "ext" marker) are ignored.
+func ParseSKeyChallenge(s string) (SKeyChallenge, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 || !strings.HasPrefix(strings.ToLower(fields[0]), "otp-") {
+		return SKeyChallenge{}, fmt.Errorf("%w: %q", ErrInvalidSKeyChallenge, s)
+	}
+
+	h, err := SKeyHashFromStr(fields[0])
+	if err != nil {
+		return SKeyChallenge{}, err
+	}
+
+	seq, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return SKeyChallenge{}, fmt.Errorf("%w: invalid sequence %q", ErrInvalidSKeyChallenge, fields[1])
+	}
+
+	if err := validateSKeySeed(fields[2]); err != nil {
+		return SKeyChallenge{}, err
+	}
+
+	return SKeyChallenge{Hash: h, Sequence: uint(seq), Seed: fields[2]}, nil
+}
+
+func (c SKeyChallenge) String() string {
+	return fmt.Sprintf("otp-%s %d %s", c.Hash, c.Sequence, c.Seed)
+}
+
+// Generate computes the response to the challenge from the user's pass phrase.
+func (c SKeyChallenge) Generate(passphrase string) (SKeyOTP, error) {
+	return GenerateSKey(passphrase, c.Seed, c.Sequence, c.Hash)
+}
+
+// GenerateSKey computes the RFC 2289 one-time password at position sequence of the chain
+// defined by passphrase and seed: the folded hash of seed||passphrase, hashed and folded
+// sequence more times. The seed is case-insensitive and lower-cased before hashing.
+//
+// RFC 2289 recommends pass phrases of at least 10 characters.
+func GenerateSKey(passphrase, seed string, sequence uint, hash SKeyHash) (SKeyOTP, error) {
+	if err := validateSKeySeed(seed); err != nil {
+		return SKeyOTP{}, err
+	}
+	if hash != SKeyMD5 && hash != SKeySHA1 {
+		return SKeyOTP{}, ErrUnsupportedAlgorithm
+	}
+
+	otp := skeyFold(hash, []byte(strings.ToLower(seed)+passphrase))
+	for i := uint(0); i < sequence; i++ {
+		otp = skeyFold(hash, otp[:])
+	}
+
+	return otp, nil
+}
+
+// skeyFold hashes data and folds the digest to 64 bits as specified in RFC 2289 Appendix A.
+func skeyFold(hash SKeyHash, data []byte) SKeyOTP {
+	var out SKeyOTP
+
+	switch hash {
+	case SKeySHA1:
+		sum := sha1.Sum(data)
+		var w [5]uint32
+		for i := range w {
+			w[i] = binary.BigEndian.Uint32(sum[i*4:])
+		}
+		// The reference implementation stores the folded words little-endian.
+		binary.LittleEndian.PutUint32(out[0:], w[0]^w[2]^w[4])
+		binary.LittleEndian.PutUint32(out[4:], w[1]^w[3])
+	default:
+		sum := md5.Sum(data)
+		for i := range out {
+			out[i] = sum[i] ^ sum[i+8]
+		}
+	}
+
+	return out
+}
+
+// validateSKeySeed enforces RFC 2289 §6.0: 1 to 16 alphanumeric characters.
+func validateSKeySeed(seed string) error {
+	if len(seed) < 1 || len(seed) > 16 {
+		return fmt.Errorf("%w: must be 1 to 16 characters", ErrInvalidSKeySeed)
+	}
+	for _, c := range seed {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return fmt.Errorf("%w: must be alphanumeric", ErrInvalidSKeySeed)
+		}
+	}
+	return nil
+}
+
+// SKeyState is the server-side record of an RFC 2289 chain for one user.
+//
+// The server stores the last accepted OTP, which sits at position Sequence of the chain.
+// It challenges for position Sequence-1 and accepts a response whose hash folds to the
+// stored OTP, after which the response becomes the new stored OTP. The pass phrase is
+// never stored.
+type SKeyState struct {
+	Hash     SKeyHash `json:"hash"`
+	Sequence uint     `json:"sequence"`
+	Seed     string   `json:"seed"`
+	Last     SKeyOTP  `json:"last"`
+}
+
+// NewSKeyState initializes a chain for the user, e.g. on enrollment or when the previous
+// chain is exhausted. The first challenge is for sequence-1.
+func NewSKeyState(passphrase, seed string, sequence uint, hash SKeyHash) (SKeyState, error) {
+	if sequence == 0 {
+		return SKeyState{}, ErrSKeyExhausted
+	}
+
+	otp, err := GenerateSKey(passphrase, seed, sequence, hash)
+	if err != nil {
+		return SKeyState{}, err
+	}
+
+	return SKeyState{Hash: hash, Sequence: sequence, Seed: seed, Last: otp}, nil
+}
+
+// Challenge returns the challenge the user must answer next.
+// It returns ErrSKeyExhausted once the chain has been used up.
+func (s SKeyState) Challenge() (SKeyChallenge, error) {
+	if s.Sequence == 0 {
+		return SKeyChallenge{}, ErrSKeyExhausted
+	}
+	return SKeyChallenge{Hash: s.Hash, Sequence: s.Sequence - 1, Seed: s.Seed}, nil
+}
+
+// Verify checks a six-word or hexadecimal response to the current challenge.
+// On success it stores the response and decrements Sequence; the caller must persist
+// the updated state atomically before reporting success, so the response cannot be
+// replayed. SKeyVerifier does so with an SKeyStore.
+func (s *SKeyState) Verify(response string) (bool, error) {
+	if s.Sequence == 0 {
+		return false, ErrSKeyExhausted
+	}
+
+	otp, err := ParseSKeyOTP(response)
+	if err != nil {
+		return false, err
+	}
+
+	next := skeyFold(s.Hash, otp[:])
+	if subtle.ConstantTimeCompare(next[:], s.Last[:]) != 1 {
+		return false, ErrInvalidCode
+	}
+
+	s.Last = otp
+	s.Sequence--
+
+	return true, nil
+}
@@ -0,0 +1,194 @@
+package otp
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// RFC 2289 Appendix C test vectors.
+var skeyVectors = []struct {
+	hash       SKeyHash
+	passphrase string
+	seed       string
+	sequence   uint
+	hex        string
+	words      string
+}{
+	{SKeyMD5, "This is a test.", "TeSt", 0, "9E876134D90499DD", "INCH SEA ANNE LONG AHEM TOUR"},
+	{SKeyMD5, "This is a test.", "TeSt", 1, "7965E05436F5029F", "EASE OIL FUM CURE AWRY AVIS"},
+	{SKeyMD5, "This is a test.", "TeSt", 99, "50FE1962C4965880", "BAIL TUFT BITS GANG CHEF THY"},
+	{SKeyMD5, "AbCdEfGhIjK", "alpha1", 0, "87066DD9644BF206", "FULL PEW DOWN ONCE MORT ARC"},
+	{SKeyMD5, "AbCdEfGhIjK", "alpha1", 1, "7CD34C1040ADD14B", "FACT HOOF AT FIST SITE KENT"},
+	{SKeyMD5, "AbCdEfGhIjK", "alpha1", 99, "5AA37A81F212146C", "BODE HOP JAKE STOW JUT RAP"},
+	{SKeyMD5, "OTP's are good", "correct", 0, "F205753943DE4CF9", "ULAN NEW ARMY FUSE SUIT EYED"},
+	{SKeyMD5, "OTP's are good", "correct", 1, "DDCDAC956F234937", "SKIM CULT LOB SLAM POE HOWL"},
+	{SKeyMD5, "OTP's are good", "correct", 99, "B203E28FA525BE47", "LONG IVY JULY AJAR BOND LEE"},
+	{SKeySHA1, "This is a test.", "TeSt", 0, "BB9E6AE1979D8FF4", "MILT VARY MAST OK SEES WENT"},
+	{SKeySHA1, "This is a test.", "TeSt", 1, "63D936639734385B", "CART OTTO HIVE ODE VAT NUT"},
+	{SKeySHA1, "This is a test.", "TeSt", 99, "87FEC7768B73CCF9", "GAFF WAIT SKID GIG SKY EYED"},
+	{SKeySHA1, "AbCdEfGhIjK", "alpha1", 0, "AD85F658EBE383C9", "LEST OR HEEL SCOT ROB SUIT"},
+	{SKeySHA1, "AbCdEfGhIjK", "alpha1", 1, "D07CE229B5CF119B", "RITE TAKE GELD COST TUNE RECK"},
+	{SKeySHA1, "AbCdEfGhIjK", "alpha1", 99, "27BC71035AAF3DC6", "MAY STAR TIN LYON VEDA STAN"},
+	{SKeySHA1, "OTP's are good", "correct", 0, "D51F3E99BF8E6F0B", "RUST WELT KICK FELL TAIL FRAU"},
+	{SKeySHA1, "OTP's are good", "correct", 1, "82AEB52D943774E4", "FLIT DOSE ALSO MEW DRUM DEFY"},
+	{SKeySHA1, "OTP's are good", "correct", 99, "4F296A74FE1567EC", "AURA ALOE HURL WING BERG WAIT"},
+}
+
+func TestGenerateSKey_RFC2289(t *testing.T) {
+	for _, tt := range skeyVectors {
+		name := tt.hash.String() + "/" + tt.seed
+		t.Run(name, func(t *testing.T) {
+			otp, err := GenerateSKey(tt.passphrase, tt.seed, tt.sequence, tt.hash)
+			if err != nil {
+				t.Fatalf("GenerateSKey failed: %v", err)
+			}
+			if got := otp.Hex(); got != tt.hex {
+				t.Errorf("sequence %d: hex = %s, want %s", tt.sequence, got, tt.hex)
+			}
+			if got := otp.Words(); got != tt.words {
+				t.Errorf("sequence %d: words = %s, want %s", tt.sequence, got, tt.words)
+			}
+		})
+	}
+}
+
+func TestParseSKeyOTP(t *testing.T) {
+	for _, tt := range skeyVectors {
+		for _, in := range []string{
+			tt.words,
+			strings.ToLower(tt.words),
+			"word:" + tt.words,
+			tt.hex,
+			tt.hex[:4] + " " + tt.hex[4:8] + " " + tt.hex[8:12] + " " + tt.hex[12:],
+			"hex:" + strings.ToLower(tt.hex),
+		} {
+			otp, err := ParseSKeyOTP(in)
+			if err != nil {
+				t.Fatalf("ParseSKeyOTP(%q) failed: %v", in, err)
+			}
+			if otp.Hex() != tt.hex {
+				t.Errorf("ParseSKeyOTP(%q) = %s, want %s", in, otp.Hex(), tt.hex)
+			}
+		}
+	}
+
+	invalid := []string{
+		"",
+		"INCH SEA ANNE LONG AHEM",           // five words
+		"INCH SEA ANNE LONG AHEM TOURS",     // unknown word
+		"INCH SEA ANNE LONG AHEM TOUT",      // checksum mismatch
+		"hex:9E876134D90499",                // short hex
+		"word:9E876134D90499DD",             // hex with word prefix
+		"INCH SEA ANNE LONG AHEM TOUR TOUR", // seven words
+	}
+	for _, in := range invalid {
+		if _, err := ParseSKeyOTP(in); !errors.Is(err, ErrInvalidSKeyResponse) {
+			t.Errorf("ParseSKeyOTP(%q) = %v, want ErrInvalidSKeyResponse", in, err)
+		}
+	}
+}
+
+func TestSKeyDictionary(t *testing.T) {
+	for i, w := range skeyWords {
+		idx, ok := skeyWordIndex(w)
+		if !ok || idx != uint64(i) {
+			t.Fatalf("skeyWordIndex(%q) = %d, %v; want %d", w, idx, ok, i)
+		}
+		if (i < skeyShortWords) != (len(w) < 4) {
+			t.Fatalf("word %q at %d is in the wrong group", w, i)
+		}
+	}
+}
+
+func TestParseSKeyChallenge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SKeyChallenge
+		wantErr bool
+	}{
+		{in: "otp-sha1 499 ke1234", want: SKeyChallenge{SKeySHA1, 499, "ke1234"}},
+		{in: "otp-md5 99 TeSt ext", want: SKeyChallenge{SKeyMD5, 99, "TeSt"}},
+		{in: "OTP-MD5 0 alpha1", want: SKeyChallenge{SKeyMD5, 0, "alpha1"}},
+		{in: "otp-md4 99 TeSt", wantErr: true},
+		{in: "s/key 99 TeSt", wantErr: true},
+		{in: "otp-md5 -1 TeSt", wantErr: true},
+		{in: "otp-md5 99 not-alnum", wantErr: true},
+		{in: "otp-md5 99 seedlongerthansixteen", wantErr: true},
+		{in: "otp-md5 99", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSKeyChallenge(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	c := SKeyChallenge{SKeySHA1, 499, "ke1234"}
+	if c.String() != "otp-sha1 499 ke1234" {
+		t.Errorf("String() = %q", c.String())
+	}
+}
+
+func TestSKeyState_Verify(t *testing.T) {
+	const passphrase = "This is a test."
+
+	state, err := NewSKeyState(passphrase, "TeSt", 3, SKeyMD5)
+	if err != nil {
+		t.Fatalf("NewSKeyState failed: %v", err)
+	}
+
+	for seq := uint(2); ; seq-- {
+		c, err := state.Challenge()
+		if err != nil {
+			t.Fatalf("Challenge failed: %v", err)
+		}
+		if c.Sequence != seq {
+			t.Fatalf("challenge sequence = %d, want %d", c.Sequence, seq)
+		}
+
+		otp, err := c.Generate(passphrase)
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+
+		// A response for the wrong position is rejected without advancing.
+		wrong, _ := GenerateSKey(passphrase, "TeSt", seq+1, SKeyMD5)
+		if ok, err := state.Verify(wrong.Words()); ok || !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("Verify(wrong) = %v, %v", ok, err)
+		}
+
+		if ok, err := state.Verify(otp.Words()); !ok || err != nil {
+			t.Fatalf("Verify(seq %d) = %v, %v", seq, ok, err)
+		}
+
+		// Replaying the accepted response fails.
+		if ok, _ := state.Verify(otp.Hex()); ok {
+			t.Fatalf("replayed response accepted at seq %d", seq)
+		}
+
+		if seq == 0 {
+			break
+		}
+	}
+
+	if _, err := state.Challenge(); !errors.Is(err, ErrSKeyExhausted) {
+		t.Errorf("expected ErrSKeyExhausted, got %v", err)
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded SKeyState
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded != state {
+		t.Errorf("JSON round-trip = %+v, %v; want %+v", decoded, err, state)
+	}
+}
@@ -0,0 +1,107 @@
+package otp
+
+import "sync"
+
+// SKeyStore persists the SKeyState of each S/KEY chain.
+//
+// Implementations must be safe for concurrent use. CompareAndSwap must be atomic,
+// e.g. an `UPDATE ... SET sequence = ?, last = ? WHERE id = ? AND sequence = ? AND last = ?`
+// in SQL.
+type SKeyStore interface {
+	// Load returns the state of the chain id.
+	Load(id string) (SKeyState, error)
+
+	// CompareAndSwap sets the state of id to new only if it currently equals old,
+	// and reports whether the swap happened.
+	CompareAndSwap(id string, old, new SKeyState) (bool, error)
+}
+
+// SKeyVerifier verifies S/KEY responses against chains kept in an SKeyStore and
+// persists the advanced chain after every successful verification.
+//
+// Concurrent submissions of the same response race on CompareAndSwap, and only one of
+// them succeeds; the others fail with ErrCodeReused.
+type SKeyVerifier struct {
+	store SKeyStore
+}
+
+// NewSKeyVerifier returns an SKeyVerifier using store for chains.
+// If store is nil, a new in-memory store is used.
+func NewSKeyVerifier(store SKeyStore) *SKeyVerifier {
+	if store == nil {
+		store = NewMemorySKeyStore()
+	}
+	return &SKeyVerifier{store: store}
+}
+
+// Challenge returns the challenge the user of chain id must answer next.
+func (v *SKeyVerifier) Challenge(id string) (SKeyChallenge, error) {
+	state, err := v.store.Load(id)
+	if err != nil {
+		return SKeyChallenge{}, err
+	}
+	return state.Challenge()
+}
+
+// Verify checks a response to the current challenge of chain id and, on success,
+// stores the advanced chain.
+func (v *SKeyVerifier) Verify(id, response string) (bool, error) {
+	old, err := v.store.Load(id)
+	if err != nil {
+		return false, err
+	}
+
+	state := old
+	if ok, err := state.Verify(response); !ok {
+		return false, err
+	}
+
+	swapped, err := v.store.CompareAndSwap(id, old, state)
+	if err != nil {
+		return false, err
+	}
+	if !swapped {
+		// Another submission advanced the chain past this response.
+		return false, ErrCodeReused
+	}
+
+	return true, nil
+}
+
+// MemorySKeyStore is an in-memory SKeyStore. Unknown ids have an exhausted zero state.
+type MemorySKeyStore struct {
+	mu     sync.Mutex
+	states map[string]SKeyState
+}
+
+// NewMemorySKeyStore returns an empty in-memory SKeyStore.
+func NewMemorySKeyStore() *MemorySKeyStore {
+	return &MemorySKeyStore{states: make(map[string]SKeyState)}
+}
+
+// Set initializes or overwrites the state of id, e.g. on enrollment.
+func (s *MemorySKeyStore) Set(id string, state SKeyState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[id] = state
+}
+
+// Load implements SKeyStore.
+func (s *MemorySKeyStore) Load(id string) (SKeyState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[id], nil
+}
+
+// CompareAndSwap implements SKeyStore.
+func (s *MemorySKeyStore) CompareAndSwap(id string, old, new SKeyState) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.states[id] != old {
+		return false, nil
+	}
+	s.states[id] = new
+
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestSKeyVerifier_Verify(t *testing.T) {
+	const passphrase = "This is a test."
+
+	state, err := NewSKeyState(passphrase, "TeSt", 2, SKeyMD5)
+	if err != nil {
+		t.Fatalf("NewSKeyState failed: %v", err)
+	}
+	store := NewMemorySKeyStore()
+	store.Set("alice", state)
+	v := NewSKeyVerifier(store)
+
+	seq1, _ := GenerateSKey(passphrase, "TeSt", 1, SKeyMD5)
+	seq0, _ := GenerateSKey(passphrase, "TeSt", 0, SKeyMD5)
+
+	tests := []struct {
+		name     string
+		response string
+		sequence uint // expected stored sequence afterwards
+		wantErr  error
+	}{
+		{"wrong position", seq0.Words(), 2, ErrInvalidCode},
+		{"sequence 1", seq1.Words(), 1, nil},
+		{"replay sequence 1", seq1.Hex(), 1, ErrInvalidCode},
+		{"sequence 0", seq0.Hex(), 0, nil},
+		{"exhausted", seq0.Words(), 0, ErrSKeyExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := v.Verify("alice", tt.response)
+			if ok != (tt.wantErr == nil) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, %v, want error %v", ok, err, tt.wantErr)
+			}
+			if s, _ := store.Load("alice"); s.Sequence != tt.sequence {
+				t.Errorf("stored sequence = %d, want %d", s.Sequence, tt.sequence)
+			}
+		})
+	}
+
+	if _, err := v.Challenge("bob"); !errors.Is(err, ErrSKeyExhausted) {
+		t.Errorf("Challenge(unknown) = %v, want ErrSKeyExhausted", err)
+	}
+}
+
+func TestSKeyVerifier_ConcurrentSameResponse(t *testing.T) {
+	const passphrase = "This is a test."
+	otp, _ := GenerateSKey(passphrase, "TeSt", 1, SKeyMD5)
+
+	for round := 0; round < 50; round++ {
+		state, err := NewSKeyState(passphrase, "TeSt", 2, SKeyMD5)
+		if err != nil {
+			t.Fatalf("NewSKeyState failed: %v", err)
+		}
+		store := NewMemorySKeyStore()
+		store.Set("alice", state)
+		v := NewSKeyVerifier(store)
+
+		var (
+			wg       sync.WaitGroup
+			start    = make(chan struct{})
+			accepted = make([]bool, 2)
+		)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				accepted[i], _ = v.Verify("alice", otp.Words())
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+
+		if accepted[0] == accepted[1] {
+			t.Fatalf("round %d: accepted = %v, want exactly one", round, accepted)
+		}
+	}
+}
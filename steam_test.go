@@ -0,0 +1,107 @@
+package otp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateSteam(t *testing.T) {
+	// Base32 of "12345678901234567890", reference values computed from the
+	// Steam Guard algorithm over the RFC 6238 test times.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{59, "PV9M4"},
+		{1111111109, "PY4YB"},
+		{1234567890, "VHHQY"},
+		{2000000000, "9N776"},
+	}
+
+	for _, tt := range tests {
+		got, err := GenerateSteam(secret, time.Unix(tt.time, 0), nil)
+		if err != nil {
+			t.Fatalf("GenerateSteam failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("GenerateSteam(%d) = %s, want %s", tt.time, got, tt.want)
+		}
+		for _, c := range got {
+			if !strings.ContainsRune(steamAlphabet, c) {
+				t.Errorf("code %s contains %q outside the Steam alphabet", got, c)
+			}
+		}
+	}
+}
+
+func TestValidateSteam(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+	}{
+		{"exact", "PY4YB", true},
+		{"lower case", "py4yb", true},
+		{"wrong code", "22222", false},
+		{"wrong length", "PY4Y", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := ValidateSteam(secret, tt.code, now, nil)
+			if ok != tt.valid {
+				t.Errorf("ValidateSteam() = %v, %v; want %v", ok, err, tt.valid)
+			}
+		})
+	}
+
+	// Default skew accepts the previous step, but not two steps back.
+	prev, _ := GenerateSteam(secret, now.Add(-30*time.Second), nil)
+	if ok, _ := ValidateSteam(secret, prev, now, nil); !ok {
+		t.Errorf("expected previous step to be accepted")
+	}
+	old, _ := GenerateSteam(secret, now.Add(-60*time.Second), nil)
+	if ok, _ := ValidateSteam(secret, old, now, nil); ok {
+		t.Errorf("expected code two steps back to be rejected")
+	}
+}
+
+func TestSteamURL(t *testing.T) {
+	u, err := GenerateSteamURL(URLParam{
+		AccountName: "alice",
+		Secret:      "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+	})
+	if err != nil {
+		t.Fatalf("GenerateSteamURL failed: %v", err)
+	}
+
+	for _, want := range []string{"encoder=steam", "issuer=Steam", "digits=5", "period=30"} {
+		if !strings.Contains(u.String(), want) {
+			t.Errorf("URL %s missing %s", u, want)
+		}
+	}
+
+	for _, raw := range []string{
+		u.String(),
+		"otpauth://steam/Steam:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+	} {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("url.Parse failed: %v", err)
+		}
+		p, err := ParseOTPAuthURL(parsed)
+		if err != nil {
+			t.Fatalf("ParseOTPAuthURL(%s) failed: %v", raw, err)
+		}
+		if p.Encoder != SteamEncoderName || p.Digits != 5 {
+			t.Errorf("ParseOTPAuthURL(%s) = encoder %q digits %d", raw, p.Encoder, p.Digits)
+		}
+	}
+}
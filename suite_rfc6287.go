@@ -120,7 +120,7 @@ func (cfg SuiteConfig) Validate() error {
 	if cfg.Digits < 4 || cfg.Digits > 10 {
 		return fmt.Errorf("invalid digit length: %d", cfg.Digits)
 	}
-	if cfg.Hash != SHA1 && cfg.Hash != SHA256 && cfg.Hash != SHA512 {
+	if _, err := lookupAlgorithm(cfg.Hash); err != nil {
 		return fmt.Errorf("unsupported hash algorithm: %v", cfg.Hash)
 	}
 	if cfg.IncludePassword && cfg.PasswordHash == PasswordNone {
@@ -226,29 +226,25 @@ func parseRawSuite(raw string) (SuiteConfig, error) {
 }
 
 // parseCryptoFunction handles the "HOTP-SHA1-6" or "HOTP-SHA256-8" part.
+// The hash may be any registered algorithm, including names with dashes ("HOTP-SHA3-256-8").
 func parseCryptoFunction(raw, crypto string) (SuiteConfig, error) {
-	if !strings.HasPrefix(strings.ToUpper(crypto), "HOTP-SHA") {
+	if !strings.HasPrefix(strings.ToUpper(crypto), "HOTP-") {
 		return SuiteConfig{}, fmt.Errorf("unknown or unsupported crypto in %q", raw)
 	}
 	rest := crypto[5:]
-	parts := strings.Split(rest, "-")
-	if len(parts) != 2 {
+	i := strings.LastIndex(rest, "-")
+	if i <= 0 {
 		return SuiteConfig{}, fmt.Errorf("invalid crypto format: %q", rest)
 	}
-	hashPart := parts[0] // "SHA256"
-	digPart := parts[1]  // "8" or "6", etc.
+	hashPart := rest[:i]  // "SHA256"
+	digPart := rest[i+1:] // "8" or "6", etc.
 
 	var cfg SuiteConfig
-	switch strings.ToUpper(hashPart) {
-	case "SHA1":
-		cfg.Hash = SHA1
-	case "SHA256":
-		cfg.Hash = SHA256
-	case "SHA512":
-		cfg.Hash = SHA512
-	default:
+	algo, err := ParseAlgorithm(hashPart)
+	if err != nil {
 		return SuiteConfig{}, fmt.Errorf("unsupported hash %q", hashPart)
 	}
+	cfg.Hash = algo
 
 	dig, err := strconv.Atoi(digPart)
 	if err != nil {
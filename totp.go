@@ -16,6 +16,7 @@ var DefaultTOTPParam = &Param{
 }
 
 // GenerateTOTP generates a TOTP code based on the given secret and timestamp.
+// If t is zero, the current time from param.Clock is used.
 // If param is nil, DefaultTOTPParam is used.
 // The secret must be encoded according to the specified algorithm's encoding (e.g., base32 for SHA1).
 func GenerateTOTP(secret string, t time.Time, param *Param) (string, error) {
@@ -29,7 +30,17 @@ func GenerateTOTP(secret string, t time.Time, param *Param) (string, error) {
 		return "", err
 	}
 
-	return deriveRFC4226(secretBuf, TimeCounterFunc(t, param.Period), param.Digits.Int(), param.Algorithm)
+	period := param.Period
+	if period == 0 {
+		period = 30
+	}
+
+	counter, err := param.timeCounter(t, period)
+	if err != nil {
+		return "", err
+	}
+
+	return deriveCode(secretBuf, counter, param)
 }
 
 // GenerateTOTPURL constructs an otpauth:// URL for configuring TOTP-based authenticators (e.g., Google Authenticator).
@@ -48,8 +59,20 @@ func GenerateTOTPURL(param URLParam) (*url.URL, error) {
 
 // ValidateTOTP checks whether the given TOTP code is valid for the specified time and secret.
 // It uses constant-time comparison to avoid timing attacks.
-// Returns true if the code is valid, false otherwise. If param is nil, DefaultTOTPParam is used.
+// Returns true if the code is valid, false otherwise. If t is zero, the current time from
+// param.Clock is used. If param is nil, DefaultTOTPParam is used.
 func ValidateTOTP(secret, code string, t time.Time, param *Param) (bool, error) {
+	if _, err := ValidateTOTPResult(secret, code, t, param); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidateTOTPResult is like ValidateTOTP but reports which time step of the skew
+// window matched. The returned Offset is the signed distance, in steps, from the
+// step of t; a positive value means the client clock runs ahead of the server.
+// If param is nil, DefaultTOTPParam is used.
+func ValidateTOTPResult(secret, code string, t time.Time, param *Param) (ValidationResult, error) {
 	if param == nil {
 		_def := *DefaultTOTPParam
 		param = &_def
@@ -57,23 +80,44 @@ func ValidateTOTP(secret, code string, t time.Time, param *Param) (bool, error)
 
 	secretBuf, err := DecodeSecret(secret)
 	if err != nil {
-		return false, err
+		return ValidationResult{}, err
 	}
 
+	return matchTOTP(secretBuf, code, t, param)
+}
+
+// matchTOTP walks the skew window around the time step of t and returns the
+// first step whose code matches.
+func matchTOTP(secret []byte, code string, t time.Time, param *Param) (ValidationResult, error) {
+	return matchTimeWindow(t, param, func(counter uint64) (bool, error) {
+		return validateCode(code, secret, counter, param)
+	})
+}
+
+// matchTimeWindow calls validateFn for every time step of the skew window around t,
+// from the oldest to the newest, and returns the first step it accepts.
+// With param.ConstantTime, every step is evaluated whatever the outcome.
+func matchTimeWindow(t time.Time, param *Param, validateFn func(counter uint64) (bool, error)) (ValidationResult, error) {
 	period := param.Period
 	if period == 0 {
 		period = 30
 	}
 
-	skew := param.Skew
-	counter := TimeCounterFunc(t, period)
+	counter, err := param.timeCounter(t, period)
+	if err != nil {
+		return ValidationResult{}, err
+	}
 
-	for i := -int64(skew); i <= int64(skew); i++ {
-		valid, err := validateRFC4226(code, secretBuf, counter+uint64(i), param.Digits, param.Algorithm)
-		if err == nil && valid {
-			return true, nil
-		}
+	c, offset, ok := scanWindow(counter, param.Skew, param.ConstantTime, validateFn)
+	if !ok {
+		return ValidationResult{}, ErrInvalidCode
 	}
 
-	return false, ErrInvalidCode
+	start := param.stepStart(c, period)
+	return ValidationResult{
+		Counter:   c,
+		Offset:    offset,
+		StepStart: start,
+		StepEnd:   start.Add(time.Duration(period) * time.Second),
+	}, nil
 }
@@ -1,7 +1,9 @@
 package otp
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"strings"
 	"testing"
@@ -116,3 +118,249 @@ func TestGenerateTOTPURL(t *testing.T) {
 		t.Errorf("Invalid otpauth URL: %v", err)
 	}
 }
+
+func TestValidateTOTPResult(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+	param := &Param{Digits: EightDigits, Period: 30, Skew: 2, Algorithm: SHA1}
+	step := TimeCounterFunc(now, 30)
+
+	tests := []struct {
+		name   string
+		shift  time.Duration
+		offset int64
+	}{
+		{"exact", 0, 0},
+		{"client one step behind", -30 * time.Second, -1},
+		{"client two steps ahead", 60 * time.Second, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := GenerateTOTP(secret, now.Add(tt.shift), param)
+			if err != nil {
+				t.Fatalf("GenerateTOTP failed: %v", err)
+			}
+
+			res, err := ValidateTOTPResult(secret, code, now, param)
+			if err != nil {
+				t.Fatalf("ValidateTOTPResult error: %v", err)
+			}
+			if res.Offset != tt.offset {
+				t.Errorf("Offset = %d, want %d", res.Offset, tt.offset)
+			}
+			if want := uint64(int64(step) + tt.offset); res.Counter != want {
+				t.Errorf("Counter = %d, want %d", res.Counter, want)
+			}
+			if got := res.StepEnd.Sub(res.StepStart); got != 30*time.Second {
+				t.Errorf("step length = %v, want 30s", got)
+			}
+			if ts := now.Add(tt.shift); ts.Before(res.StepStart) || !ts.Before(res.StepEnd) {
+				t.Errorf("time %v outside matched step [%v, %v)", ts, res.StepStart, res.StepEnd)
+			}
+		})
+	}
+
+	if _, err := ValidateTOTPResult(secret, "00000000", now, param); err != ErrInvalidCode {
+		t.Errorf("expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestTOTP_T0AndClock(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	// With T0 = 1000, t = 1059 is step 1, like t = 59 in the RFC 6238 vectors.
+	param := &Param{Digits: EightDigits, Period: 30, Algorithm: SHA1, T0: 1000}
+	code, err := GenerateTOTP(secret, time.Unix(1059, 0), param)
+	if err != nil || code != "94287082" {
+		t.Fatalf("GenerateTOTP with T0 = %s, %v; want 94287082", code, err)
+	}
+
+	res, err := ValidateTOTPResult(secret, code, time.Unix(1059, 0), param)
+	if err != nil {
+		t.Fatalf("ValidateTOTPResult failed: %v", err)
+	}
+	if res.Counter != 1 || !res.StepStart.Equal(time.Unix(1030, 0)) || !res.StepEnd.Equal(time.Unix(1060, 0)) {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	if _, err := GenerateTOTP(secret, time.Unix(999, 0), param); !errors.Is(err, ErrTimeBeforeT0) {
+		t.Errorf("expected ErrTimeBeforeT0, got %v", err)
+	}
+
+	// A zero time uses the per-param clock; two params with different clocks
+	// do not interfere with each other.
+	clockA := &Param{Digits: EightDigits, Period: 30, Algorithm: SHA1, Clock: func() time.Time { return time.Unix(59, 0) }}
+	clockB := &Param{Digits: EightDigits, Period: 30, Algorithm: SHA1, Clock: func() time.Time { return time.Unix(1111111109, 0) }}
+
+	if code, _ := GenerateTOTP(secret, time.Time{}, clockA); code != "94287082" {
+		t.Errorf("clock A: got %s, want 94287082", code)
+	}
+	if code, _ := GenerateTOTP(secret, time.Time{}, clockB); code != "07081804" {
+		t.Errorf("clock B: got %s, want 07081804", code)
+	}
+	if ok, err := ValidateTOTP(secret, "07081804", time.Time{}, clockB); !ok || err != nil {
+		t.Errorf("ValidateTOTP with clock = %v, %v", ok, err)
+	}
+
+	// An explicit time takes precedence over the clock.
+	if code, _ := GenerateTOTP(secret, time.Unix(1111111109, 0), clockA); code != "07081804" {
+		t.Errorf("explicit time: got %s, want 07081804", code)
+	}
+}
+
+func TestValidate_ConstantTime(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+	step := TimeCounterFunc(now, 30)
+
+	// Both modes agree on every offset of the window, and beyond it.
+	for shift := int64(-3); shift <= 3; shift++ {
+		at := now.Add(time.Duration(shift) * 30 * time.Second)
+		for _, ct := range []bool{false, true} {
+			param := &Param{Digits: EightDigits, Period: 30, Skew: 2, Algorithm: SHA1, ConstantTime: ct}
+
+			code, _ := GenerateTOTP(secret, at, param)
+			res, err := ValidateTOTPResult(secret, code, now, param)
+			if shift < -2 || shift > 2 {
+				if err != ErrInvalidCode {
+					t.Errorf("TOTP shift %d, constant-time %v: expected ErrInvalidCode, got %v", shift, ct, err)
+				}
+				continue
+			}
+			if err != nil || res.Offset != shift || res.Counter != uint64(int64(step)+shift) {
+				t.Errorf("TOTP shift %d, constant-time %v: got %+v, %v", shift, ct, res, err)
+			}
+
+			code, _ = GenerateHOTP(secret, uint64(100+shift), param)
+			hres, err := ValidateHOTPResult(secret, code, 100, param)
+			if err != nil || hres.Offset != shift || hres.Counter != uint64(100+shift) {
+				t.Errorf("HOTP shift %d, constant-time %v: got %+v, %v", shift, ct, hres, err)
+			}
+		}
+	}
+
+	// Every step is evaluated, and the oldest match wins.
+	tests := []struct {
+		name       string
+		ct         bool
+		matches    map[uint64]bool
+		wantCalls  int
+		wantOffset int64
+		wantErr    error
+	}{
+		{name: "early return", matches: map[uint64]bool{8: true, 11: true}, wantCalls: 1, wantOffset: -2},
+		{name: "early return, late match", matches: map[uint64]bool{12: true}, wantCalls: 5, wantOffset: 2},
+		{name: "constant time", ct: true, matches: map[uint64]bool{8: true, 11: true}, wantCalls: 5, wantOffset: -2},
+		{name: "constant time, second match", ct: true, matches: map[uint64]bool{11: true, 12: true}, wantCalls: 5, wantOffset: 1},
+		{name: "constant time, no match", ct: true, wantCalls: 5, wantErr: ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			res, err := matchCounterWindow(10, &Param{Skew: 2, ConstantTime: tt.ct}, func(c uint64) (bool, error) {
+				calls++
+				if tt.matches[c] {
+					return true, nil
+				}
+				return false, ErrInvalidCode
+			})
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("validateFn called %d times, want %d", calls, tt.wantCalls)
+			}
+			if err == nil && (res.Offset != tt.wantOffset || res.Counter != uint64(10+tt.wantOffset)) {
+				t.Errorf("got %+v, want offset %d", res, tt.wantOffset)
+			}
+		})
+	}
+
+	// Counters below zero are skipped in both modes.
+	calls := 0
+	res, err := matchCounterWindow(0, &Param{Skew: 2, ConstantTime: true}, func(c uint64) (bool, error) {
+		calls++
+		return c == 0, nil
+	})
+	if err != nil || res.Counter != 0 || res.Offset != 0 || calls != 3 {
+		t.Errorf("window at zero: got %+v, %v after %d calls", res, err, calls)
+	}
+}
+
+func TestValidate_ConstantTimeForward(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	// Forward-only scans evaluate the whole range in constant-time mode.
+	for _, ct := range []bool{false, true} {
+		calls := 0
+		c, offset, ok := scanRange(10, 0, 3, ct, func(c uint64) (bool, error) {
+			calls++
+			return c == 11, nil
+		})
+		wantCalls := 2
+		if ct {
+			wantCalls = 4
+		}
+		if !ok || c != 11 || offset != 1 || calls != wantCalls {
+			t.Errorf("constant-time %v: got %d, %d, %v after %d calls", ct, c, offset, ok, calls)
+		}
+	}
+
+	// Counters past the top of the range are skipped.
+	calls := 0
+	if _, _, ok := scanRange(math.MaxUint64, 0, 2, true, func(uint64) (bool, error) { calls++; return false, nil }); ok || calls != 1 {
+		t.Errorf("range at max: ok=%v after %d calls", ok, calls)
+	}
+
+	// HOTPVerifier and ResyncHOTP agree in both modes.
+	for _, ct := range []bool{false, true} {
+		param := &Param{Digits: SixDigits, Skew: 3, Algorithm: SHA1, ConstantTime: ct}
+		code, _ := GenerateHOTP(secret, 7, param)
+
+		store := NewMemoryCounterStore()
+		store.Set("token", 5)
+		if res, err := NewHOTPVerifier(store, param).Validate("token", secret, code); err != nil || res.Counter != 7 || res.Offset != 2 {
+			t.Errorf("HOTPVerifier, constant-time %v: got %+v, %v", ct, res, err)
+		}
+
+		next, _ := GenerateHOTP(secret, 8, param)
+		if res, err := ResyncHOTP(secret, []string{code, next}, 0, 10, param); err != nil || res.Counter != 8 || res.Offset != 7 {
+			t.Errorf("ResyncHOTP, constant-time %v: got %+v, %v", ct, res, err)
+		}
+		if _, err := ResyncHOTP(secret, []string{next, code}, 0, 10, param); err != ErrInvalidCode {
+			t.Errorf("ResyncHOTP out of order, constant-time %v: got %v", ct, err)
+		}
+	}
+}
+
+func BenchmarkValidateTOTP_ConstantTime(b *testing.B) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+
+	for _, ct := range []bool{false, true} {
+		param := &Param{Digits: EightDigits, Algorithm: SHA1, Period: 30, Skew: 10, ConstantTime: ct}
+		key, err := NewKey(secret, param)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// The oldest step is the best case for early return; an invalid code the worst.
+		early, _ := GenerateTOTP(secret, now.Add(-10*30*time.Second), param)
+
+		for _, tc := range []struct{ name, code string }{{"hit", early}, {"miss", "00000000"}} {
+			b.Run(fmt.Sprintf("constant%v/ValidateTOTP/%s", ct, tc.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, _ = ValidateTOTP(secret, tc.code, now, param)
+				}
+			})
+			b.Run(fmt.Sprintf("constant%v/Key.ValidateTOTP/%s", ct, tc.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, _ = key.ValidateTOTP(tc.code, now)
+				}
+			})
+		}
+	}
+}
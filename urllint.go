@@ -0,0 +1,202 @@
+package otp
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// AppProfile describes which otpauth parameters an authenticator app honors. Apps
+// typically ignore what they do not support instead of rejecting the URL, and then
+// generate codes that never match. Nil lists mean any value is supported.
+//
+// The built-in profiles reflect the current releases of each app on both iOS and
+// Android, taking the more restrictive of the two.
+type AppProfile struct {
+	// Name is the display name of the app.
+	Name string
+
+	// HOTP reports whether counter-based accounts are supported.
+	HOTP bool
+
+	// Algorithms lists the supported HMAC algorithms.
+	Algorithms []Algorithm
+
+	// Digits lists the supported code lengths.
+	Digits []Digits
+
+	// Periods lists the supported TOTP periods in seconds.
+	Periods []uint
+
+	// Encoders lists the supported `encoder` extensions, such as "steam".
+	Encoders []string
+}
+
+// Profiles of popular authenticator apps.
+var (
+	GoogleAuthenticator = &AppProfile{
+		Name:       "Google Authenticator",
+		HOTP:       true,
+		Algorithms: []Algorithm{SHA1},
+		Digits:     []Digits{SixDigits},
+		Periods:    []uint{30},
+	}
+	MicrosoftAuthenticator = &AppProfile{
+		Name:       "Microsoft Authenticator",
+		Algorithms: []Algorithm{SHA1},
+		Digits:     []Digits{SixDigits},
+		Periods:    []uint{30},
+	}
+	Authy = &AppProfile{
+		Name:       "Authy",
+		Algorithms: []Algorithm{SHA1},
+		Digits:     []Digits{SixDigits, 7, EightDigits},
+		Periods:    []uint{30},
+	}
+	FreeOTP = &AppProfile{
+		Name:       "FreeOTP",
+		HOTP:       true,
+		Algorithms: []Algorithm{SHA1, SHA256, SHA512},
+		Digits:     []Digits{SixDigits, EightDigits},
+	}
+	Aegis = &AppProfile{
+		Name:       "Aegis",
+		HOTP:       true,
+		Algorithms: []Algorithm{SHA1, SHA256, SHA512},
+		Encoders:   []string{SteamEncoderName},
+	}
+	OnePassword = &AppProfile{
+		Name:       "1Password",
+		Algorithms: []Algorithm{SHA1, SHA256, SHA512},
+		Digits:     []Digits{SixDigits, 7, EightDigits},
+	}
+
+	// AppProfiles are the profiles checked by LintOTPAuthURL by default.
+	AppProfiles = []*AppProfile{
+		GoogleAuthenticator,
+		MicrosoftAuthenticator,
+		Authy,
+		FreeOTP,
+		Aegis,
+		OnePassword,
+	}
+)
+
+// LintWarning is a compatibility problem of an otpauth URL.
+type LintWarning struct {
+	// App is the name of the affected app, or empty if the problem affects all apps.
+	App string
+
+	// Param is the URL parameter at fault, e.g. "digits", or "label".
+	Param string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (w LintWarning) String() string {
+	if w.App == "" {
+		return fmt.Sprintf("%s: %s", w.Param, w.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", w.App, w.Param, w.Message)
+}
+
+// minLintSecretSize is the secret length, in bytes, below which LintOTPAuthURL warns
+// (RFC 4226 §4 requires 128 bits and recommends 160).
+const minLintSecretSize = 16
+
+// LintOTPAuthURL reports the problems that would keep u from working in each app of
+// apps, or of AppProfiles if none are given, followed by problems that affect every app:
+// whatever ParseOTPAuthURLStrict rejects, a missing issuer parameter and a short secret.
+// It returns an error only if u cannot be parsed at all by ParseOTPAuthURL.
+func LintOTPAuthURL(u *url.URL, apps ...*AppProfile) ([]LintWarning, error) {
+	param, err := ParseOTPAuthURL(u)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) == 0 {
+		apps = AppProfiles
+	}
+
+	hotp := strings.EqualFold(u.Host, "hotp")
+	var warnings []LintWarning
+	for _, app := range apps {
+		warnings = append(warnings, app.lint(param, hotp)...)
+	}
+
+	if _, err := ParseOTPAuthURLStrict(u); err != nil {
+		warnings = append(warnings, LintWarning{Param: "url", Message: err.Error()})
+	}
+	if !u.Query().Has("issuer") {
+		warnings = append(warnings, LintWarning{Param: "issuer", Message: "missing issuer parameter; some apps show no issuer or take it from the label"})
+	}
+	if strings.Contains(param.AccountName, ":") {
+		warnings = append(warnings, LintWarning{Param: "label", Message: "account name contains a colon"})
+	}
+	if secret, err := DecodeSecret(param.Secret); err == nil && len(secret) < minLintSecretSize {
+		warnings = append(warnings, LintWarning{Param: "secret", Message: fmt.Sprintf("secret is %d bits, shorter than 128 bits", len(secret)*8)})
+	}
+
+	return warnings, nil
+}
+
+func (app *AppProfile) lint(param *URLParam, hotp bool) []LintWarning {
+	var warnings []LintWarning
+	warn := func(name, format string, args ...any) {
+		warnings = append(warnings, LintWarning{App: app.Name, Param: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if hotp && !app.HOTP {
+		warn("type", "HOTP accounts are not supported")
+	}
+	if app.Algorithms != nil && !slices.Contains(app.Algorithms, param.Algorithm) {
+		warn("algorithm", "%s is ignored, codes are computed with %s", param.Algorithm, app.Algorithms[0])
+	}
+	if enc, _ := ParseEncoder(param.Encoder); enc != DecimalEncoder {
+		if !slices.Contains(app.Encoders, param.Encoder) {
+			warn("encoder", "encoder %q is not supported, codes are decimal", param.Encoder)
+		}
+	} else if app.Digits != nil && !slices.Contains(app.Digits, param.Digits) {
+		warn("digits", "%d digits are not supported", param.Digits)
+	}
+	if !hotp && app.Periods != nil && !slices.Contains(app.Periods, param.Period) {
+		warn("period", "a %d-second period is ignored, codes change every %d seconds", param.Period, app.Periods[0])
+	}
+
+	return warnings
+}
+
+// GenerateCompatibleTOTPURL is like GenerateTOTPURL but produces the URL understood the
+// same way by every app in AppProfiles: SHA1, 6 digits, a 30-second period, no encoder,
+// image or color, and the issuer in both the label and the issuer parameter.
+//
+// Algorithm, Digits and Period of param are overridden, so codes must be validated with
+// the returned Param rather than one derived from param. Issuer must not contain a colon.
+func GenerateCompatibleTOTPURL(param URLParam) (*url.URL, *Param, error) {
+	if strings.Contains(param.Issuer, ":") {
+		return nil, nil, fmt.Errorf("issuer %q contains a colon", param.Issuer)
+	}
+	if strings.Contains(param.AccountName, ":") {
+		return nil, nil, fmt.Errorf("account name %q contains a colon", param.AccountName)
+	}
+
+	p := &Param{
+		Digits:    SixDigits,
+		Period:    30,
+		Algorithm: SHA1,
+	}
+
+	param.Algorithm = p.Algorithm
+	param.Digits = p.Digits
+	param.Period = p.Period
+	param.Encoder = ""
+	param.Image = ""
+	param.Color = ""
+
+	u, err := GenerateTOTPURL(param)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, p, nil
+}
@@ -0,0 +1,147 @@
+package otp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLintOTPAuthURL(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name   string
+		rawURL string
+		apps   []*AppProfile
+		want   []string // LintWarning.String() of every expected warning
+	}{
+		{
+			name:   "compatible",
+			rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example",
+		},
+		{
+			name:   "sha256 and 8 digits",
+			rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example&algorithm=SHA256&digits=8",
+			apps:   []*AppProfile{GoogleAuthenticator, FreeOTP, Authy},
+			want: []string{
+				"Google Authenticator: algorithm: SHA256 is ignored, codes are computed with SHA1",
+				"Google Authenticator: digits: 8 digits are not supported",
+				"Authy: algorithm: SHA256 is ignored, codes are computed with SHA1",
+			},
+		},
+		{
+			name:   "60-second period",
+			rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example&period=60",
+			apps:   []*AppProfile{MicrosoftAuthenticator, Aegis},
+			want: []string{
+				"Microsoft Authenticator: period: a 60-second period is ignored, codes change every 30 seconds",
+			},
+		},
+		{
+			name:   "hotp",
+			rawURL: "otpauth://hotp/Example:alice?secret=" + secret + "&issuer=Example&counter=0",
+			apps:   []*AppProfile{GoogleAuthenticator, OnePassword},
+			want: []string{
+				"1Password: type: HOTP accounts are not supported",
+			},
+		},
+		{
+			name:   "steam",
+			rawURL: "otpauth://totp/Steam:alice?secret=" + secret + "&issuer=Steam&digits=5&encoder=steam",
+			apps:   []*AppProfile{Aegis, FreeOTP},
+			want: []string{
+				`FreeOTP: encoder: encoder "steam" is not supported, codes are decimal`,
+			},
+		},
+		{
+			name:   "explicit decimal encoder",
+			rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example&encoder=decimal",
+		},
+		{
+			name:   "all apps",
+			rawURL: "otpauth://totp/Example:alice?secret=" + secret + "&issuer=Example&digits=7",
+			want: []string{
+				"Google Authenticator: digits: 7 digits are not supported",
+				"Microsoft Authenticator: digits: 7 digits are not supported",
+				"FreeOTP: digits: 7 digits are not supported",
+				"url: unsupported digits value: 7",
+			},
+		},
+		{
+			name:   "generic problems",
+			rawURL: "otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQ",
+			apps:   []*AppProfile{Aegis},
+			want: []string{
+				"issuer: missing issuer parameter; some apps show no issuer or take it from the label",
+				"secret: secret is 80 bits, shorter than 128 bits",
+			},
+		},
+		{
+			name:   "issuer mismatch",
+			rawURL: "otpauth://totp/Old:alice?secret=" + secret + "&issuer=New",
+			apps:   []*AppProfile{Aegis},
+			want: []string{
+				`url: issuer parameter does not match the label issuer: label "Old", parameter "New"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.rawURL)
+			warnings, err := LintOTPAuthURL(u, tt.apps...)
+			if err != nil {
+				t.Fatalf("LintOTPAuthURL failed: %v", err)
+			}
+
+			got := make([]string, len(warnings))
+			for i, w := range warnings {
+				got[i] = w.String()
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("warnings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	u, _ := url.Parse("otpauth://foo/Example:alice?secret=" + secret)
+	if _, err := LintOTPAuthURL(u); err == nil {
+		t.Error("expected error for unparsable URL")
+	}
+}
+
+func TestGenerateCompatibleTOTPURL(t *testing.T) {
+	u, param, err := GenerateCompatibleTOTPURL(URLParam{
+		Issuer:      "Example",
+		AccountName: "alice@example.com",
+		Secret:      "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		Algorithm:   SHA512,
+		Digits:      EightDigits,
+		Period:      60,
+		Encoder:     SteamEncoderName,
+		Color:       "FF0000",
+	})
+	if err != nil {
+		t.Fatalf("GenerateCompatibleTOTPURL failed: %v", err)
+	}
+
+	if warnings, _ := LintOTPAuthURL(u); len(warnings) != 0 {
+		t.Errorf("compatible URL has warnings: %v", warnings)
+	}
+	if u.String() != "otpauth://totp/Example:alice@example.com?algorithm=SHA1&digits=6&issuer=Example&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("URL = %s", u)
+	}
+
+	// Codes from the URL's parameters validate with the returned Param.
+	parsed, _ := ParseOTPAuthURL(u)
+	now := time.Unix(1700000000, 0)
+	code, _ := GenerateTOTP(parsed.Secret, now, &Param{Digits: parsed.Digits, Period: parsed.Period, Algorithm: parsed.Algorithm})
+	if ok, err := ValidateTOTP(parsed.Secret, code, now, param); !ok || err != nil {
+		t.Errorf("ValidateTOTP with returned Param = %v, %v", ok, err)
+	}
+
+	if _, _, err := GenerateCompatibleTOTPURL(URLParam{Issuer: "https://example.com", AccountName: "alice", Secret: "GEZDGNBVGY3TQOJQ"}); err == nil {
+		t.Error("expected error for issuer with colon")
+	}
+}
@@ -0,0 +1,361 @@
+package otp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// YKValStatus is the status of a YK-VAL (YubiKey validation protocol 2.0) response.
+type YKValStatus string
+
+const (
+	YKValOK                  YKValStatus = "OK"
+	YKValBadOTP              YKValStatus = "BAD_OTP"
+	YKValReplayedOTP         YKValStatus = "REPLAYED_OTP"
+	YKValBadSignature        YKValStatus = "BAD_SIGNATURE"
+	YKValMissingParameter    YKValStatus = "MISSING_PARAMETER"
+	YKValNoSuchClient        YKValStatus = "NO_SUCH_CLIENT"
+	YKValOperationNotAllowed YKValStatus = "OPERATION_NOT_ALLOWED"
+	YKValBackendError        YKValStatus = "BACKEND_ERROR"
+	YKValNotEnoughAnswers    YKValStatus = "NOT_ENOUGH_ANSWERS"
+	YKValReplayedRequest     YKValStatus = "REPLAYED_REQUEST"
+)
+
+// ykvalTimeLayout is the layout of the `t` field; it is followed by 4 digits of milliseconds.
+const ykvalTimeLayout = "2006-01-02T15:04:05Z"
+
+// YKValResponse is a YK-VAL verify response.
+//
+// On the wire it is a list of `key=value` lines separated by CRLF, signed with the
+// `h` field: the base64 HMAC-SHA1, keyed with the client API key, of all other fields
+// sorted by key and joined as `k1=v1&k2=v2`.
+type YKValResponse struct {
+	Status YKValStatus
+	OTP    string
+	Nonce  string
+	Time   time.Time
+
+	// The following fields are only set when the request asked for `timestamp=1`
+	// and Status is OK.
+	Timestamp      uint32
+	SessionCounter uint16
+	SessionUse     uint8
+	HasTimestamp   bool
+}
+
+// fields returns the response fields, excluding the signature.
+func (r YKValResponse) fields() map[string]string {
+	ms := r.Time.Nanosecond() / int(time.Millisecond)
+	f := map[string]string{
+		"status": string(r.Status),
+		"t":      r.Time.UTC().Format(ykvalTimeLayout) + fmt.Sprintf("%04d", ms),
+	}
+	if r.OTP != "" {
+		f["otp"] = r.OTP
+	}
+	if r.Nonce != "" {
+		f["nonce"] = r.Nonce
+	}
+	if r.HasTimestamp {
+		f["timestamp"] = strconv.FormatUint(uint64(r.Timestamp), 10)
+		f["sessioncounter"] = strconv.FormatUint(uint64(r.SessionCounter), 10)
+		f["sessionuse"] = strconv.FormatUint(uint64(r.SessionUse), 10)
+	}
+	return f
+}
+
+// MarshalYKVal encodes r in the YK-VAL response format, signed with apiKey.
+// If apiKey is empty the `h` field is omitted.
+func (r YKValResponse) MarshalYKVal(apiKey []byte) []byte {
+	f := r.fields()
+
+	var buf bytes.Buffer
+	if len(apiKey) > 0 {
+		buf.WriteString("h=" + ykvalSign(f, apiKey) + "\r\n")
+	}
+	for _, k := range sortedKeys(f) {
+		buf.WriteString(k + "=" + f[k] + "\r\n")
+	}
+	return buf.Bytes()
+}
+
+// ParseYKValResponse parses a YK-VAL response and, if apiKey is not empty, verifies its
+// signature. Unknown fields are ignored but covered by the signature check.
+func ParseYKValResponse(body []byte, apiKey []byte) (YKValResponse, error) {
+	f := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return YKValResponse{}, fmt.Errorf("%w: malformed line %q", ErrInvalidYKValResponse, line)
+		}
+		f[k] = v
+	}
+	if err := sc.Err(); err != nil {
+		return YKValResponse{}, err
+	}
+
+	if len(apiKey) > 0 {
+		h := f["h"]
+		delete(f, "h")
+		if !hmac.Equal([]byte(h), []byte(ykvalSign(f, apiKey))) {
+			return YKValResponse{}, fmt.Errorf("%w: bad signature", ErrInvalidYKValResponse)
+		}
+	}
+
+	r := YKValResponse{
+		Status: YKValStatus(f["status"]),
+		OTP:    f["otp"],
+		Nonce:  f["nonce"],
+	}
+	if r.Status == "" {
+		return YKValResponse{}, fmt.Errorf("%w: missing status", ErrInvalidYKValResponse)
+	}
+	if t := f["t"]; len(t) > len(ykvalTimeLayout) {
+		ts, err := time.Parse(ykvalTimeLayout, t[:len(ykvalTimeLayout)])
+		ms, err2 := strconv.Atoi(t[len(ykvalTimeLayout):])
+		if err != nil || err2 != nil {
+			return YKValResponse{}, fmt.Errorf("%w: invalid t %q", ErrInvalidYKValResponse, t)
+		}
+		r.Time = ts.Add(time.Duration(ms) * time.Millisecond)
+	}
+	if ts, ok := f["timestamp"]; ok {
+		v, err := strconv.ParseUint(ts, 10, 32)
+		sc, err2 := strconv.ParseUint(f["sessioncounter"], 10, 16)
+		su, err3 := strconv.ParseUint(f["sessionuse"], 10, 8)
+		if err != nil || err2 != nil || err3 != nil {
+			return YKValResponse{}, fmt.Errorf("%w: invalid timestamp fields", ErrInvalidYKValResponse)
+		}
+		r.Timestamp, r.SessionCounter, r.SessionUse, r.HasTimestamp = uint32(v), uint16(sc), uint8(su), true
+	}
+
+	return r, nil
+}
+
+// ykvalSign returns the base64 HMAC-SHA1 of the sorted fields.
+func ykvalSign(f map[string]string, apiKey []byte) string {
+	pairs := make([]string, 0, len(f))
+	for _, k := range sortedKeys(f) {
+		pairs = append(pairs, k+"="+f[k])
+	}
+	mac := hmac.New(sha1.New, apiKey)
+	mac.Write([]byte(strings.Join(pairs, "&")))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func sortedKeys(f map[string]string) []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// YKValServer is an http.Handler implementing the YK-VAL 2.0 verify endpoint
+// (`/wsapi/2.0/verify`) on top of a YubicoVerifier, so that existing YubiKey clients and
+// PAM modules can be pointed at a local validation server instead of YubiCloud.
+type YKValServer struct {
+	verifier *YubicoVerifier
+
+	mu      sync.RWMutex
+	clients map[string][]byte
+
+	now func() time.Time
+}
+
+// NewYKValServer returns a YKValServer that validates OTPs with verifier.
+// Clients must be registered with AddClient before they can verify.
+func NewYKValServer(verifier *YubicoVerifier) *YKValServer {
+	return &YKValServer{
+		verifier: verifier,
+		clients:  make(map[string][]byte),
+		now:      time.Now,
+	}
+}
+
+// AddClient registers a client id and its API key. Requests from the client must be
+// signed with the key, and responses to it are signed with it. An empty key disables
+// request signature checks and response signing for the client.
+func (s *YKValServer) AddClient(id string, apiKey []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[id] = apiKey
+}
+
+// ServeHTTP implements http.Handler. It accepts GET and POST requests.
+func (s *YKValServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, apiKey := s.verify(r.Form)
+	res.Time = s.now()
+
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write(res.MarshalYKVal(apiKey))
+}
+
+func (s *YKValServer) verify(form url.Values) (YKValResponse, []byte) {
+	res := YKValResponse{OTP: form.Get("otp"), Nonce: form.Get("nonce")}
+
+	id := form.Get("id")
+	if id == "" || res.OTP == "" || res.Nonce == "" {
+		res.Status = YKValMissingParameter
+		return res, nil
+	}
+
+	s.mu.RLock()
+	apiKey, ok := s.clients[id]
+	s.mu.RUnlock()
+	if !ok {
+		res.Status = YKValNoSuchClient
+		return res, nil
+	}
+
+	if len(apiKey) > 0 {
+		f := make(map[string]string, len(form))
+		for k := range form {
+			if k != "h" {
+				f[k] = form.Get(k)
+			}
+		}
+		if !hmac.Equal([]byte(form.Get("h")), []byte(ykvalSign(f, apiKey))) {
+			res.Status = YKValBadSignature
+			return res, apiKey
+		}
+	}
+
+	if len(res.Nonce) < 16 || len(res.Nonce) > 40 {
+		res.Status = YKValMissingParameter
+		return res, apiKey
+	}
+
+	token, err := s.verifier.Verify(res.OTP)
+	switch {
+	case err == nil:
+		res.Status = YKValOK
+		if form.Get("timestamp") == "1" {
+			res.Timestamp = token.Timestamp
+			res.SessionCounter = token.UseCounter
+			res.SessionUse = token.SessionCounter
+			res.HasTimestamp = true
+		}
+	case errors.Is(err, ErrCodeReused):
+		res.Status = YKValReplayedOTP
+	case errors.Is(err, ErrInvalidCode), errors.Is(err, ErrInvalidYubicoOTP), errors.Is(err, ErrUnknownYubiKey):
+		res.Status = YKValBadOTP
+	default:
+		res.Status = YKValBackendError
+	}
+
+	return res, apiKey
+}
+
+// YKValClient verifies Yubico OTPs against a YK-VAL 2.0 server, such as YubiCloud
+// or a YKValServer.
+type YKValClient struct {
+	// URL is the verify endpoint, e.g. "https://api.yubico.com/wsapi/2.0/verify".
+	URL string
+
+	// ClientID is the client id registered with the server.
+	ClientID string
+
+	// APIKey is the client API key. If set, requests are signed and response
+	// signatures are verified.
+	APIKey []byte
+
+	// HTTPClient is used to send requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// Verify sends otp to the server with a fresh nonce and returns the verified response.
+//
+// The response must be correctly signed and echo the OTP and nonce. A REPLAYED_OTP status
+// is returned as ErrCodeReused, BAD_OTP as ErrInvalidCode and other non-OK statuses as an
+// error wrapping ErrYKValStatus; the response is returned alongside these errors.
+func (c *YKValClient) Verify(ctx context.Context, otp string) (YKValResponse, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return YKValResponse{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	f := map[string]string{
+		"id":    c.ClientID,
+		"otp":   otp,
+		"nonce": hex.EncodeToString(nonce),
+	}
+
+	q := url.Values{}
+	for k, v := range f {
+		q.Set(k, v)
+	}
+	if len(c.APIKey) > 0 {
+		q.Set("h", ykvalSign(f, c.APIKey))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"?"+q.Encode(), nil)
+	if err != nil {
+		return YKValResponse{}, err
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return YKValResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return YKValResponse{}, fmt.Errorf("%w: http status %d", ErrInvalidYKValResponse, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return YKValResponse{}, err
+	}
+
+	res, err := ParseYKValResponse(body, c.APIKey)
+	if err != nil {
+		return YKValResponse{}, err
+	}
+
+	switch res.Status {
+	case YKValOK:
+	case YKValReplayedOTP, YKValReplayedRequest:
+		return res, ErrCodeReused
+	case YKValBadOTP:
+		return res, ErrInvalidCode
+	default:
+		return res, fmt.Errorf("%w: %s", ErrYKValStatus, res.Status)
+	}
+
+	if res.OTP != otp || res.Nonce != f["nonce"] {
+		return YKValResponse{}, fmt.Errorf("%w: otp or nonce mismatch", ErrInvalidYKValResponse)
+	}
+
+	return res, nil
+}
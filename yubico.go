@@ -0,0 +1,354 @@
+package otp
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// modhexAlphabet is the Yubico "modified hexadecimal" alphabet. Its characters sit on the
+// same keys in most keyboard layouts, so a YubiKey typing as a USB keyboard is understood
+// regardless of the host layout.
+const modhexAlphabet = "cbdefghijklnrtuv"
+
+const (
+	// YubicoPrivateIDSize is the size of the private (secret) identity inside a Yubico OTP.
+	YubicoPrivateIDSize = 6
+
+	// YubicoKeySize is the size of the AES-128 key of a YubiKey slot.
+	YubicoKeySize = 16
+
+	// yubicoTokenSize is the size of the encrypted part of a Yubico OTP.
+	yubicoTokenSize = 16
+
+	// yubicoMaxPublicIDSize is the largest public ID a YubiKey can be programmed with.
+	yubicoMaxPublicIDSize = 16
+
+	// yubicoCRCResidue is the CRC-16 (ISO 13239) of a token that includes its own checksum.
+	yubicoCRCResidue = 0xF0B8
+)
+
+// ModhexEncode encodes b in modhex.
+func ModhexEncode(b []byte) string {
+	out := make([]byte, len(b)*2)
+	for i, c := range b {
+		out[i*2] = modhexAlphabet[c>>4]
+		out[i*2+1] = modhexAlphabet[c&0x0F]
+	}
+	return string(out)
+}
+
+// ModhexDecode decodes a modhex string. Decoding is case-insensitive.
+func ModhexDecode(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd modhex length", ErrInvalidYubicoOTP)
+	}
+
+	out := make([]byte, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		hi := strings.IndexByte(modhexAlphabet, lowerASCII(s[i]))
+		lo := strings.IndexByte(modhexAlphabet, lowerASCII(s[i+1]))
+		if hi < 0 || lo < 0 {
+			return nil, fmt.Errorf("%w: invalid modhex character at %d", ErrInvalidYubicoOTP, i)
+		}
+		out[i/2] = byte(hi<<4 | lo)
+	}
+
+	return out, nil
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// YubicoToken is the decrypted content of a Yubico OTP.
+type YubicoToken struct {
+	// PrivateID is the secret identity programmed into the YubiKey slot.
+	PrivateID [YubicoPrivateIDSize]byte
+
+	// UseCounter is the non-volatile counter, incremented on every power-up
+	// (and when SessionCounter wraps).
+	UseCounter uint16
+
+	// Timestamp is a 24-bit, 8 Hz timer started at a random value on power-up.
+	Timestamp uint32
+
+	// SessionCounter is incremented on every OTP generated within a power-up session.
+	SessionCounter uint8
+
+	// Random is random data added by the YubiKey.
+	Random uint16
+}
+
+// Counter returns the replay counter of the token.
+func (t YubicoToken) Counter() YubicoCounter {
+	return YubicoCounter{UseCounter: t.UseCounter, SessionCounter: t.SessionCounter}
+}
+
+// MarshalBinary returns the 16-byte plaintext token, including its CRC.
+// Multi-byte fields are little-endian, as on the device.
+func (t YubicoToken) MarshalBinary() ([]byte, error) {
+	out := make([]byte, 0, yubicoTokenSize)
+	out = append(out, t.PrivateID[:]...)
+	out = binary.LittleEndian.AppendUint16(out, t.UseCounter)
+	out = append(out, byte(t.Timestamp), byte(t.Timestamp>>8), byte(t.Timestamp>>16))
+	out = append(out, t.SessionCounter)
+	out = binary.LittleEndian.AppendUint16(out, t.Random)
+	out = binary.LittleEndian.AppendUint16(out, ^yubicoCRC16(out))
+	return out, nil
+}
+
+// UnmarshalBinary parses a 16-byte plaintext token and verifies its CRC.
+func (t *YubicoToken) UnmarshalBinary(data []byte) error {
+	if len(data) != yubicoTokenSize {
+		return fmt.Errorf("%w: token must be %d bytes", ErrInvalidYubicoOTP, yubicoTokenSize)
+	}
+	if yubicoCRC16(data) != yubicoCRCResidue {
+		// A CRC mismatch is what a wrong key or a guessed OTP looks like.
+		return fmt.Errorf("%w: crc mismatch", ErrInvalidCode)
+	}
+
+	copy(t.PrivateID[:], data[:6])
+	t.UseCounter = binary.LittleEndian.Uint16(data[6:])
+	t.Timestamp = uint32(data[8]) | uint32(data[9])<<8 | uint32(data[10])<<16
+	t.SessionCounter = data[11]
+	t.Random = binary.LittleEndian.Uint16(data[12:])
+
+	return nil
+}
+
+// yubicoCRC16 is the CRC-16 of ISO 13239 (reflected polynomial 0x8408, initial value 0xFFFF).
+func yubicoCRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			lsb := crc & 1
+			crc >>= 1
+			if lsb != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
+
+// ParseYubicoOTP splits a Yubico OTP into its modhex public ID and the 16-byte encrypted
+// token. The OTP is 32 modhex characters of token, preceded by up to 32 characters of
+// public ID (12 on factory-programmed keys).
+func ParseYubicoOTP(otp string) (publicID string, token []byte, err error) {
+	otp = strings.ToLower(strings.TrimSpace(otp))
+	if len(otp) < yubicoTokenSize*2 || len(otp) > (yubicoTokenSize+yubicoMaxPublicIDSize)*2 {
+		return "", nil, fmt.Errorf("%w: invalid length %d", ErrInvalidYubicoOTP, len(otp))
+	}
+
+	split := len(otp) - yubicoTokenSize*2
+	if _, err := ModhexDecode(otp[:split]); err != nil {
+		return "", nil, err
+	}
+	token, err = ModhexDecode(otp[split:])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return otp[:split], token, nil
+}
+
+// DecryptYubicoToken decrypts the 16-byte token of a Yubico OTP with the AES-128 key
+// of the YubiKey and verifies its CRC.
+func DecryptYubicoToken(token, key []byte) (YubicoToken, error) {
+	if len(token) != yubicoTokenSize {
+		return YubicoToken{}, fmt.Errorf("%w: token must be %d bytes", ErrInvalidYubicoOTP, yubicoTokenSize)
+	}
+	if len(key) != YubicoKeySize {
+		return YubicoToken{}, fmt.Errorf("invalid AES-128 key length %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return YubicoToken{}, err
+	}
+
+	plain := make([]byte, yubicoTokenSize)
+	block.Decrypt(plain, token)
+
+	var t YubicoToken
+	if err := t.UnmarshalBinary(plain); err != nil {
+		return YubicoToken{}, err
+	}
+	return t, nil
+}
+
+// GenerateYubicoOTP encrypts token with key and prefixes it with the modhex publicID,
+// producing what a YubiKey would type. It is useful to emulate a YubiKey in tests.
+func GenerateYubicoOTP(publicID string, key []byte, token YubicoToken) (string, error) {
+	if len(key) != YubicoKeySize {
+		return "", fmt.Errorf("invalid AES-128 key length %d", len(key))
+	}
+	if id, err := ModhexDecode(publicID); err != nil || len(id) > yubicoMaxPublicIDSize {
+		return "", fmt.Errorf("%w: invalid public id %q", ErrInvalidYubicoOTP, publicID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	plain, _ := token.MarshalBinary()
+	out := make([]byte, yubicoTokenSize)
+	block.Encrypt(out, plain)
+
+	return strings.ToLower(publicID) + ModhexEncode(out), nil
+}
+
+// YubicoCounter is the replay state of a YubiKey: the last accepted use and session counters.
+type YubicoCounter struct {
+	UseCounter     uint16
+	SessionCounter uint8
+}
+
+// Less reports whether c is strictly older than o. An OTP is only accepted if the stored
+// counter is Less than the counter of the OTP.
+func (c YubicoCounter) Less(o YubicoCounter) bool {
+	if c.UseCounter != o.UseCounter {
+		return c.UseCounter < o.UseCounter
+	}
+	return c.SessionCounter < o.SessionCounter
+}
+
+// YubicoCredential is the server-side record of a YubiKey slot.
+type YubicoCredential struct {
+	// PublicID is the modhex public ID that prefixes every OTP of the key.
+	PublicID string
+
+	// PrivateID is the secret identity expected inside every decrypted token.
+	PrivateID [YubicoPrivateIDSize]byte
+
+	// Key is the AES-128 key of the slot.
+	Key [YubicoKeySize]byte
+
+	// Counter is the counter of the last accepted OTP.
+	Counter YubicoCounter
+}
+
+// YubicoStore persists YubiKey credentials and their replay counters.
+//
+// Implementations must be safe for concurrent use, and CompareAndSwap must be atomic.
+type YubicoStore interface {
+	// Load returns the credential with the given public ID, or ErrUnknownYubiKey.
+	Load(publicID string) (YubicoCredential, error)
+
+	// CompareAndSwap sets the counter of publicID to new only if it currently equals old,
+	// and reports whether the swap happened.
+	CompareAndSwap(publicID string, old, new YubicoCounter) (bool, error)
+}
+
+// YubicoVerifier validates Yubico OTPs against the credentials of a YubicoStore, the way a
+// YubiKey validation server (YK-KSM and YK-VAL) does.
+type YubicoVerifier struct {
+	store YubicoStore
+}
+
+// NewYubicoVerifier returns a YubicoVerifier backed by store.
+// If store is nil, a new in-memory store is used.
+func NewYubicoVerifier(store YubicoStore) *YubicoVerifier {
+	if store == nil {
+		store = NewMemoryYubicoStore()
+	}
+	return &YubicoVerifier{store: store}
+}
+
+// Verify decrypts otp with the key of its public ID, checks the CRC and the private ID,
+// and accepts it only if its counter is newer than the stored one, which is then advanced.
+//
+// It returns ErrUnknownYubiKey for an unknown public ID, an error wrapping ErrInvalidCode
+// for a token that does not decrypt to the expected identity, and ErrCodeReused for a
+// replayed or out-of-order OTP.
+func (v *YubicoVerifier) Verify(otp string) (YubicoToken, error) {
+	publicID, ciphertext, err := ParseYubicoOTP(otp)
+	if err != nil {
+		return YubicoToken{}, err
+	}
+
+	for {
+		cred, err := v.store.Load(publicID)
+		if err != nil {
+			return YubicoToken{}, err
+		}
+
+		token, err := DecryptYubicoToken(ciphertext, cred.Key[:])
+		if err != nil {
+			return YubicoToken{}, err
+		}
+		if subtle.ConstantTimeCompare(token.PrivateID[:], cred.PrivateID[:]) != 1 {
+			return YubicoToken{}, fmt.Errorf("%w: private id mismatch", ErrInvalidCode)
+		}
+
+		if !cred.Counter.Less(token.Counter()) {
+			return YubicoToken{}, ErrCodeReused
+		}
+
+		swapped, err := v.store.CompareAndSwap(publicID, cred.Counter, token.Counter())
+		if err != nil {
+			return YubicoToken{}, err
+		}
+		if swapped {
+			return token, nil
+		}
+	}
+}
+
+// MemoryYubicoStore is an in-memory YubicoStore.
+type MemoryYubicoStore struct {
+	mu    sync.Mutex
+	creds map[string]YubicoCredential
+}
+
+// NewMemoryYubicoStore returns an empty in-memory YubicoStore.
+func NewMemoryYubicoStore() *MemoryYubicoStore {
+	return &MemoryYubicoStore{creds: make(map[string]YubicoCredential)}
+}
+
+// Add registers or replaces a credential, e.g. on enrollment.
+func (s *MemoryYubicoStore) Add(cred YubicoCredential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred.PublicID = strings.ToLower(cred.PublicID)
+	s.creds[cred.PublicID] = cred
+}
+
+// Load implements YubicoStore.
+func (s *MemoryYubicoStore) Load(publicID string) (YubicoCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.creds[publicID]
+	if !ok {
+		return YubicoCredential{}, ErrUnknownYubiKey
+	}
+	return cred, nil
+}
+
+// CompareAndSwap implements YubicoStore.
+func (s *MemoryYubicoStore) CompareAndSwap(publicID string, old, new YubicoCounter) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.creds[publicID]
+	if !ok {
+		return false, ErrUnknownYubiKey
+	}
+	if cred.Counter != old {
+		return false, nil
+	}
+	cred.Counter = new
+	s.creds[publicID] = cred
+
+	return true, nil
+}
@@ -0,0 +1,258 @@
+package otp
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test vector from the libyubikey ykparse documentation.
+const (
+	yubicoTestKey = "ecde18dbe76fbd0c33330f1c354871db"
+	yubicoTestOTP = "dteffujehknhfjbrjnlnldnhcujvddbikngjrtgh"
+)
+
+func yubicoTestCredential(t *testing.T) YubicoCredential {
+	t.Helper()
+	var cred YubicoCredential
+	cred.PublicID = "dteffuje"
+	key, _ := hex.DecodeString(yubicoTestKey)
+	copy(cred.Key[:], key)
+	id, _ := hex.DecodeString("8792ebfe26cc")
+	copy(cred.PrivateID[:], id)
+	return cred
+}
+
+func TestModhex(t *testing.T) {
+	b, _ := hex.DecodeString("0123456789abcdef")
+	enc := ModhexEncode(b)
+	if enc != "cbdefghijklnrtuv" {
+		t.Fatalf("ModhexEncode = %s", enc)
+	}
+
+	dec, err := ModhexDecode(strings.ToUpper(enc))
+	if err != nil || hex.EncodeToString(dec) != "0123456789abcdef" {
+		t.Fatalf("ModhexDecode = %x, %v", dec, err)
+	}
+
+	for _, in := range []string{"cbd", "cbda", "cb0e"} {
+		if _, err := ModhexDecode(in); !errors.Is(err, ErrInvalidYubicoOTP) {
+			t.Errorf("ModhexDecode(%q) = %v, want ErrInvalidYubicoOTP", in, err)
+		}
+	}
+}
+
+func TestDecryptYubicoToken(t *testing.T) {
+	publicID, ciphertext, err := ParseYubicoOTP(yubicoTestOTP)
+	if err != nil {
+		t.Fatalf("ParseYubicoOTP failed: %v", err)
+	}
+	if publicID != "dteffuje" {
+		t.Errorf("publicID = %s", publicID)
+	}
+
+	key, _ := hex.DecodeString(yubicoTestKey)
+	token, err := DecryptYubicoToken(ciphertext, key)
+	if err != nil {
+		t.Fatalf("DecryptYubicoToken failed: %v", err)
+	}
+
+	want := YubicoToken{
+		PrivateID:      [6]byte{0x87, 0x92, 0xeb, 0xfe, 0x26, 0xcc},
+		UseCounter:     19,
+		Timestamp:      0x00c230,
+		SessionCounter: 17,
+		Random:         0x9fc8,
+	}
+	if token != want {
+		t.Errorf("token = %+v, want %+v", token, want)
+	}
+
+	// Re-encrypting the token reproduces the OTP, CRC included.
+	otp, err := GenerateYubicoOTP(publicID, key, token)
+	if err != nil || otp != yubicoTestOTP {
+		t.Errorf("GenerateYubicoOTP = %s, %v", otp, err)
+	}
+
+	// Flipping a ciphertext bit breaks the CRC.
+	ciphertext[3] ^= 0x01
+	if _, err := DecryptYubicoToken(ciphertext, key); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode for corrupted token, got %v", err)
+	}
+}
+
+func TestParseYubicoOTP_Invalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		yubicoTestOTP[9:],                    // 31 token characters
+		strings.Repeat("c", 66),              // public id too long
+		"dteffujx" + yubicoTestOTP[8:],       // non-modhex public id
+		"dteffuje" + strings.Repeat("a", 32), // non-modhex token
+	} {
+		if _, _, err := ParseYubicoOTP(in); !errors.Is(err, ErrInvalidYubicoOTP) {
+			t.Errorf("ParseYubicoOTP(%q) = %v, want ErrInvalidYubicoOTP", in, err)
+		}
+	}
+}
+
+func TestYubicoVerifier(t *testing.T) {
+	cred := yubicoTestCredential(t)
+	store := NewMemoryYubicoStore()
+	store.Add(cred)
+	v := NewYubicoVerifier(store)
+
+	token, err := v.Verify(strings.ToUpper(yubicoTestOTP))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if token.UseCounter != 19 || token.SessionCounter != 17 {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	if _, err := v.Verify(yubicoTestOTP); !errors.Is(err, ErrCodeReused) {
+		t.Errorf("replay: expected ErrCodeReused, got %v", err)
+	}
+
+	gen := func(use uint16, session uint8) string {
+		tok := token
+		tok.UseCounter, tok.SessionCounter = use, session
+		otp, err := GenerateYubicoOTP(cred.PublicID, cred.Key[:], tok)
+		if err != nil {
+			t.Fatalf("GenerateYubicoOTP failed: %v", err)
+		}
+		return otp
+	}
+
+	tests := []struct {
+		name    string
+		otp     string
+		wantErr error
+	}{
+		{"older session", gen(19, 16), ErrCodeReused},
+		{"older use counter", gen(18, 200), ErrCodeReused},
+		{"next session", gen(19, 18), nil},
+		{"new power-up", gen(20, 0), nil},
+		{"stale after power-up", gen(19, 30), ErrCodeReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.otp); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A token encrypted with the right key but carrying another private ID.
+	other := token
+	other.PrivateID[0] ^= 0xFF
+	other.UseCounter = 100
+	otp, _ := GenerateYubicoOTP(cred.PublicID, cred.Key[:], other)
+	if _, err := v.Verify(otp); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("private id mismatch: expected ErrInvalidCode, got %v", err)
+	}
+
+	unknown := "cccccccccccc" + yubicoTestOTP[8:]
+	if _, err := v.Verify(unknown); !errors.Is(err, ErrUnknownYubiKey) {
+		t.Errorf("expected ErrUnknownYubiKey, got %v", err)
+	}
+}
+
+func TestYKValResponse_RoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	res := YKValResponse{
+		Status:         YKValOK,
+		OTP:            yubicoTestOTP,
+		Nonce:          "0123456789abcdef0123",
+		Time:           time.Date(2024, 5, 1, 12, 30, 45, 123e6, time.UTC),
+		Timestamp:      0xc230,
+		SessionCounter: 19,
+		SessionUse:     17,
+		HasTimestamp:   true,
+	}
+
+	body := res.MarshalYKVal(key)
+	if !strings.Contains(string(body), "t=2024-05-01T12:30:45Z0123\r\n") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+
+	got, err := ParseYKValResponse(body, key)
+	if err != nil {
+		t.Fatalf("ParseYKValResponse failed: %v", err)
+	}
+	if got != res {
+		t.Errorf("got %+v, want %+v", got, res)
+	}
+
+	tampered := strings.Replace(string(body), "status=OK", "status=BAD_OTP", 1)
+	if _, err := ParseYKValResponse([]byte(tampered), key); !errors.Is(err, ErrInvalidYKValResponse) {
+		t.Errorf("expected ErrInvalidYKValResponse for tampered body, got %v", err)
+	}
+}
+
+func TestYKValServer(t *testing.T) {
+	cred := yubicoTestCredential(t)
+	store := NewMemoryYubicoStore()
+	store.Add(cred)
+
+	apiKey := []byte("client-api-key-0")
+	server := NewYKValServer(NewYubicoVerifier(store))
+	server.AddClient("1", apiKey)
+	server.AddClient("2", nil)
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	ctx := context.Background()
+	client := &YKValClient{URL: ts.URL + "/wsapi/2.0/verify", ClientID: "1", APIKey: apiKey}
+
+	res, err := client.Verify(ctx, yubicoTestOTP)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if res.Status != YKValOK || res.OTP != yubicoTestOTP {
+		t.Errorf("unexpected response %+v", res)
+	}
+
+	res, err = client.Verify(ctx, yubicoTestOTP)
+	if !errors.Is(err, ErrCodeReused) || res.Status != YKValReplayedOTP {
+		t.Errorf("replay: got %v, %s", err, res.Status)
+	}
+
+	res, err = client.Verify(ctx, "cccccccccccc"+yubicoTestOTP[8:])
+	if !errors.Is(err, ErrInvalidCode) || res.Status != YKValBadOTP {
+		t.Errorf("unknown key: got %v, %s", err, res.Status)
+	}
+
+	// Requests signed with the wrong key are rejected, and the client cannot
+	// verify the response signature either.
+	bad := &YKValClient{URL: client.URL, ClientID: "1", APIKey: []byte("wrong")}
+	if _, err := bad.Verify(ctx, yubicoTestOTP); !errors.Is(err, ErrInvalidYKValResponse) {
+		t.Errorf("bad key: expected ErrInvalidYKValResponse, got %v", err)
+	}
+
+	unsigned := &YKValClient{URL: client.URL, ClientID: "2"}
+	token, _ := DecryptYubicoToken(mustModhex(yubicoTestOTP[8:]), cred.Key[:])
+	token.SessionCounter++
+	otp, _ := GenerateYubicoOTP(cred.PublicID, cred.Key[:], token)
+	if _, err := unsigned.Verify(ctx, otp); err != nil {
+		t.Errorf("unsigned client: %v", err)
+	}
+
+	nobody := &YKValClient{URL: client.URL, ClientID: "3"}
+	res, err = nobody.Verify(ctx, otp)
+	if !errors.Is(err, ErrYKValStatus) || res.Status != YKValNoSuchClient {
+		t.Errorf("unknown client: got %v, %s", err, res.Status)
+	}
+}
+
+func mustModhex(s string) []byte {
+	b, err := ModhexDecode(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}